@@ -1,20 +1,172 @@
 package fetch
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"log"
+	"log/slog"
+	"maps"
+	"math"
 	"net/http"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // API represents the Fetch API server and its collective endpoints. The API
-// stores submitted receipts in memory and are not persisted across restarts,
-// but is safe for concurrent use.
+// stores submitted receipts in a [Store], by default in memory where they are
+// not persisted across restarts, and is safe for concurrent use.
 type API struct {
-	mux      *http.ServeMux
-	mu       sync.RWMutex
-	receipts map[string]*Receipt
+	mux *http.ServeMux
+	// handler is the mux wrapped by the middleware registered with Use.
+	handler    http.Handler
+	middleware []func(http.Handler) http.Handler
+	// mu serializes modifications of stored receipts so that concurrent
+	// read-modify-write operations are not lost. Held by locked.
+	mu    sync.Mutex
+	store Store
+	// storeAttempts is the maximum number of calls of retried store methods,
+	// and storeBackoff the wait before the first retry. See
+	// WithStoreRetries.
+	storeAttempts int
+	storeBackoff  time.Duration
+	// hashes maps content hashes, see contentHash, to the ID of the receipt
+	// processed with that hash, excluding deleted receipts. The hashes of the
+	// receipts in the store are loaded on first use, see loadIndex. Guarded
+	// by mu.
+	hashes   map[string]string
+	maxTotal int
+	// pointsTTL is the duration after which the points of processed
+	// receipts expire, or zero if they never expire.
+	pointsTTL time.Duration
+	// totalTolerance is the maximum difference in cents between the total
+	// and the sum of the item prices of a receipt, or negative if totals are
+	// not checked.
+	totalTolerance int
+	// maxDescriptionLength is the maximum trimmed length of item
+	// descriptions.
+	maxDescriptionLength int
+	now                  func() time.Time
+	ruleset              *Ruleset
+	errorLog             *log.Logger
+	// logger is the structured logger of all logging of the API, by default
+	// writing to errorLog if set, otherwise the default logger.
+	logger *slog.Logger
+	// dateLayouts are the accepted layouts of purchase dates, tried in order.
+	dateLayouts []string
+	// defaultPurchaseTime is used when a receipt has a purchase date but no
+	// purchase time, if set.
+	defaultPurchaseTime string
+	// alwaysRecalculate recalculates points on every fetch, ignoring the
+	// points assigned to stored receipts.
+	alwaysRecalculate bool
+	// stripCurrencySymbols strips leading currency symbols from amounts.
+	stripCurrencySymbols bool
+	// persistBreakdowns stores the breakdown of the points by rule on
+	// receipts when their points are calculated.
+	persistBreakdowns bool
+	// fieldNaming is the naming scheme of receipt and points JSON fields.
+	fieldNaming FieldNaming
+	// rejectDuplicateKeys rejects request bodies with duplicate JSON keys.
+	rejectDuplicateKeys bool
+	// receiptURLOrigins are the origins receipt URLs may be fetched from, see
+	// receiptURLOrigin.
+	receiptURLOrigins []string
+	// processSlots bounds the number of concurrent ProcessReceipt
+	// executions, if set.
+	processSlots chan struct{}
+	// maxRawRequestSize is the maximum size of the raw request bodies stored
+	// on processed receipts, or zero if raw requests are not stored.
+	maxRawRequestSize int
+	// serverTiming emits the Server-Timing header from ProcessReceipt.
+	serverTiming bool
+	// debug enables the debug endpoints.
+	debug bool
+	// trustedProxies are the proxies trusted to report the client IP
+	// address of requests.
+	trustedProxies []netip.Prefix
+	// forwardedHeader is the header trusted proxies report the client IP
+	// address of requests in.
+	forwardedHeader ForwardedHeader
+	// profilingToken is the bearer token of the endpoints of the admin
+	// handler, or empty if profiling is disabled.
+	profilingToken string
+	// processed counts the receipts submitted to ProcessReceipt by outcome.
+	processed processCounters
+	// lastErrors are the last errors produced by each endpoint, keyed by
+	// request method and endpoint pattern. Guarded by errorsMu.
+	lastErrors map[string]DebugError
+	errorsMu   sync.Mutex
+	// newHash creates the hash function used to hash receipts.
+	newHash func() hash.Hash
+	// newID generates the IDs of processed receipts.
+	newID func() (string, error)
+	// idPattern is the format of receipt IDs accepted in request paths.
+	idPattern *regexp.Regexp
+	// maintenance is the Retry-After duration of write endpoints while the
+	// API is in maintenance mode, or nil if not in maintenance.
+	maintenance atomic.Pointer[time.Duration]
+	// inflight tracks requests being served so that Close can wait for them
+	// to finish. closeMu guards closed and orders additions to inflight
+	// before Close waits on it.
+	inflight sync.WaitGroup
+	closeMu  sync.RWMutex
+	closed   bool
+	// users are the IDs of users with stored receipts, including deleted
+	// receipts, used to award the new customer bonus. The users of the
+	// receipts in the store are loaded on first use, see loadIndex. Guarded
+	// by mu.
+	users map[string]struct{}
+	// indexed reports whether the hashes and users of the receipts in the
+	// store have been loaded. Guarded by mu.
+	indexed bool
+	// reset enables the Reset endpoint of the admin handler.
+	reset bool
+	// quotas are the daily quotas of LimitDailyReceipts, cleared by Reset.
+	// Guarded by mu.
+	quotas []*dailyQuota
+	// hardDelete permanently removes receipts on delete rather than marking
+	// them as soft-deleted.
+	hardDelete bool
+}
+
+// DefaultMaxDescriptionLength is the default maximum trimmed length of item
+// descriptions accepted by the API. See [WithMaxDescriptionLength].
+const DefaultMaxDescriptionLength = 512
+
+// DefaultMaxTotal is the default maximum receipt total, represented as cents,
+// accepted by the API. See [WithMaxTotal].
+const DefaultMaxTotal = 1_000_000_00
+
+// DefaultIDPattern is the default format of receipt IDs accepted by the API,
+// matching the UUIDv4 IDs assigned to processed receipts. See [WithIDPattern].
+var DefaultIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// Option configures optional behavior of the [API].
+type Option func(*API)
+
+// WithMaxTotal sets the maximum receipt total, represented as cents, that the
+// API will accept. Receipts with a total exceeding the maximum are rejected as
+// they are almost certainly erroneous and would inflate the points awarded.
+func WithMaxTotal(cents int) Option {
+	return func(api *API) {
+		api.maxTotal = cents
+	}
 }
 
 // ProcessReceiptRequest is the request body that is submitted to the
@@ -32,8 +184,33 @@ type ProcessReceiptRequest struct {
 	// Total is the sum of all costs of line items on the receipt, represented
 	// as a string monetary value, e.g. "15.30".
 	Total string `json:"total"`
+	// ReceiptURL is the URL of the receipt JSON to process, in place of the
+	// other fields of the request, if enabled. See [WithReceiptURLHosts].
+	ReceiptURL string `json:"receiptUrl,omitempty"`
+	// Discount is an optional discount applied to the total before scoring,
+	// either a whole percentage e.g. "10%" or a fixed string monetary value
+	// e.g. "1.50".
+	Discount string `json:"discount,omitempty"`
+	// Metadata is arbitrary client data attached to the receipt, e.g. a store
+	// number or cashier ID, which is stored and returned but never affects
+	// the points awarded.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// UserID is the optional ID of the user submitting the receipt, used to
+	// award the new customer bonus on the first receipt of a user. See
+	// [Ruleset.NewCustomerBonus].
+	UserID string `json:"userId,omitempty"`
 }
 
+// maxUserIDLength is the maximum length of the user ID of a receipt.
+const maxUserIDLength = 64
+
+// Limits on the size of receipt metadata.
+const (
+	maxMetadataKeys        = 32
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 256
+)
+
 // ProcessReceiptItem is an individual line item in [ProcessReceiptRequest].
 type ProcessReceiptItem struct {
 	// ShortDescription is the description of the line item.
@@ -48,6 +225,10 @@ type ProcessReceiptItem struct {
 type ProcessReceiptResponse struct {
 	// ID is the unique ID of the receipt.
 	ID string `json:"id"`
+	// Warnings describe the non-fatal issues with the receipt which the
+	// server forgave while processing it, if any, e.g. a stripped currency
+	// symbol.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // GetPointsResponse is the response body that is returned from the
@@ -55,6 +236,36 @@ type ProcessReceiptResponse struct {
 type GetPointsResponse struct {
 	// Points are the number of Fetch rewards points assigned to the receipt.
 	Points int `json:"points"`
+	// Scored is true if points have been assigned to the receipt,
+	// distinguishing a receipt worth zero points from one whose points have
+	// not been calculated.
+	Scored bool `json:"scored"`
+	// History is the audit trail of changes to the points assigned to the
+	// receipt. History is only included when requested.
+	History []PointsEventResponse `json:"history,omitempty"`
+}
+
+// PointsEventResponse is a single change to the points assigned to a receipt
+// in [GetPointsResponse].
+type PointsEventResponse struct {
+	// Time is when the change was made, in RFC 3339 format.
+	Time string `json:"time"`
+	// OldPoints is the point value before the change.
+	OldPoints int `json:"oldPoints"`
+	// NewPoints is the point value after the change.
+	NewPoints int `json:"newPoints"`
+	// Reason is the human-readable reason for the change.
+	Reason string `json:"reason"`
+}
+
+// AdjustPointsRequest is the request body that is submitted to the
+// [AdjustPoints] endpoint.
+type AdjustPointsRequest struct {
+	// Points is the new point value to assign to the receipt.
+	Points int `json:"points"`
+	// Reason is the human-readable justification for the adjustment, e.g.
+	// "customer satisfaction".
+	Reason string `json:"reason"`
 }
 
 // Error is the response body that is returned from API endpoints when the
@@ -62,170 +273,1955 @@ type GetPointsResponse struct {
 type Error struct {
 	// Message is the human-readable error message.
 	Message string `json:"error"`
+	// Code is the machine-readable code of the error, if any, e.g.
+	// "invalid_hour".
+	Code string `json:"code,omitempty"`
+}
+
+// WithMaxDescriptionLength sets the maximum length of item descriptions, after
+// trimming leading and trailing whitespace, that the API will accept.
+// Receipts with a longer item description are rejected.
+func WithMaxDescriptionLength(n int) Option {
+	return func(api *API) {
+		api.maxDescriptionLength = n
+	}
+}
+
+// WithStrictTotals configures the API to reject receipts whose total differs
+// from the sum of the prices of their items by more than tolerance cents. A
+// tolerance of zero requires an exact match, while a small tolerance accepts
+// receipts that differ from the sum by rounding. Receipts with no items are
+// not checked. By default totals are not checked.
+func WithStrictTotals(tolerance int) Option {
+	return func(api *API) {
+		api.totalTolerance = max(tolerance, 0)
+	}
+}
+
+// WithMaxConcurrentProcessing limits the number of receipts processed
+// concurrently by the [ProcessReceipt] endpoint to n, protecting the server
+// from bursts of submissions. Requests beyond the limit respond with `503
+// Service Unavailable` and a `Retry-After` header. By default the number of
+// concurrently processed receipts is unlimited.
+//
+// WithMaxConcurrentProcessing panics if n is not positive.
+func WithMaxConcurrentProcessing(n int) Option {
+	if n <= 0 {
+		panic(fmt.Sprintf("fetch: WithMaxConcurrentProcessing requires a positive limit, got %d", n))
+	}
+
+	return func(api *API) {
+		api.processSlots = make(chan struct{}, n)
+	}
+}
+
+// WithClock sets the function used by the API to determine the current time,
+// e.g. when validating that a purchase date is not in the future. Defaults to
+// [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(api *API) {
+		api.now = now
+	}
+}
+
+// WithRuleset sets the ruleset used to calculate the points of processed
+// receipts. Defaults to [DefaultRuleset].
+func WithRuleset(rs *Ruleset) Option {
+	return func(api *API) {
+		api.ruleset = rs
+	}
+}
+
+// WithStore sets the store used to store processed receipts. Defaults to a
+// [MemoryStore].
+func WithStore(store Store) Option {
+	return func(api *API) {
+		api.store = store
+	}
+}
+
+// WithErrorLog sets the logger used to log errors that are not otherwise
+// reported to clients, e.g. store failures, as text records of the
+// [slog.TextHandler]. If nil, the default logger of the [log/slog] package is
+// used. See [WithLogger] to log structured records.
+func WithErrorLog(logger *log.Logger) Option {
+	return func(api *API) {
+		api.errorLog = logger
+	}
+}
+
+// WithLogger sets the structured logger used for all logging of the API, e.g.
+// errors that are not otherwise reported to clients such as store failures,
+// and slow requests logged by [API.LogSlowRequests], so that embedders may
+// route them into their own logging pipeline. Records carry their details as
+// attributes, e.g. the "receipt" ID and "error" of store failures. The logger
+// takes precedence over the logger set by [WithErrorLog].
+func WithLogger(logger *slog.Logger) Option {
+	return func(api *API) {
+		api.logger = logger
+	}
+}
+
+// WithHardDelete configures the API to permanently remove receipts from the
+// store on delete, rather than the default of marking them as soft-deleted
+// and retaining them for reporting.
+func WithHardDelete() Option {
+	return func(api *API) {
+		api.hardDelete = true
+	}
+}
+
+// DefaultDateLayouts are the default layouts of purchase dates accepted by the
+// API. See [WithDateLayouts].
+var DefaultDateLayouts = []string{"2006-01-02"}
+
+// WithDateLayouts sets the layouts, as accepted by [time.Parse], of purchase
+// dates accepted by the API, tried in order, e.g. "01/02/2006" for upstreams
+// sending US style dates. Dates matching multiple layouts with different
+// results are rejected as ambiguous. Defaults to [DefaultDateLayouts].
+//
+// WithDateLayouts panics if no layouts are given.
+func WithDateLayouts(layouts ...string) Option {
+	if len(layouts) == 0 {
+		panic("fetch: WithDateLayouts requires at least one layout")
+	}
+
+	layouts = slices.Clone(layouts)
+
+	return func(api *API) {
+		api.dateLayouts = layouts
+	}
+}
+
+// WithDefaultPurchaseTime sets the purchase time, in 24-hour time format e.g.
+// "12:00", used for receipts with a purchase date but no purchase time. By
+// default receipts without a purchase time are rejected.
+//
+// WithDefaultPurchaseTime panics if the purchase time is not a valid time in
+// 24-hour time format.
+func WithDefaultPurchaseTime(purchaseTime string) Option {
+	if _, err := parseTimeOfDay(purchaseTime); err != nil {
+		panic(fmt.Sprintf("fetch: WithDefaultPurchaseTime requires a valid purchase time, %v", err))
+	}
+
+	return func(api *API) {
+		api.defaultPurchaseTime = purchaseTime
+	}
+}
+
+// WithAlwaysRecalculate configures the API to recalculate the points of a
+// receipt on every fetch using the current ruleset, ignoring the points
+// assigned when the receipt was processed. This is intended for development
+// only, where rules are tweaked and live recalculation is desired, as it
+// bypasses the protection against retroactively changing point values.
+func WithAlwaysRecalculate() Option {
+	return func(api *API) {
+		api.alwaysRecalculate = true
+	}
+}
+
+// WithStripCurrencySymbols configures the API to strip a leading currency
+// symbol from the monetary values of receipts, e.g. "$1.25" is accepted as
+// "1.25", rather than rejecting them. ProcessReceipt warns of each stripped
+// symbol in its response.
+func WithStripCurrencySymbols() Option {
+	return func(api *API) {
+		api.stripCurrencySymbols = true
+	}
+}
+
+// WithPersistBreakdowns configures the API to store the breakdown of the points
+// by rule on each receipt when its points are calculated, so that explanations
+// of the points are returned as calculated rather than recalculated under the
+// current ruleset, which may have changed since.
+func WithPersistBreakdowns() Option {
+	return func(api *API) {
+		api.persistBreakdowns = true
+	}
+}
+
+// WithIDGenerator sets the function used to generate the IDs of processed
+// receipts. Generated IDs should match the accepted ID format, see
+// [WithIDPattern]. Defaults to generating UUIDv4 IDs.
+func WithIDGenerator(gen func() (string, error)) Option {
+	return func(api *API) {
+		api.newID = gen
+	}
+}
+
+// WithIDPattern sets the format of receipt IDs accepted in request paths.
+// Requests for IDs that do not match the pattern respond with `404 Not Found`
+// without accessing the store. The pattern should be anchored to match the
+// entire ID. Defaults to [DefaultIDPattern].
+func WithIDPattern(pattern *regexp.Regexp) Option {
+	return func(api *API) {
+		api.idPattern = pattern
+	}
+}
+
+// WithDebug enables the debug endpoints under `/debug/`, which expose
+// internal state of the API and should not be publicly accessible.
+func WithDebug() Option {
+	return func(api *API) {
+		api.debug = true
+	}
 }
 
-// NewAPI creates a new Fetch API.
-func NewAPI() *API {
+// NewAPI creates a new Fetch API configured with the given options.
+func NewAPI(opts ...Option) *API {
 	api := &API{
 		mux:      http.NewServeMux(),
-		receipts: make(map[string]*Receipt),
+		store:    NewMemoryStore(),
+		hashes:   make(map[string]string),
+		users:    make(map[string]struct{}),
+		maxTotal: DefaultMaxTotal,
+		now:      time.Now,
+		ruleset:  DefaultRuleset(),
+
+		maxDescriptionLength: DefaultMaxDescriptionLength,
+		totalTolerance:       -1,
+		dateLayouts:          slices.Clone(DefaultDateLayouts),
+		newID:                genUUID,
+		newHash:              sha256.New,
+		idPattern:            DefaultIDPattern,
+		lastErrors:           make(map[string]DebugError),
+	}
+
+	for _, opt := range opts {
+		opt(api)
 	}
 
-	api.mux.HandleFunc("/receipts/process", api.ProcessReceipt)
-	api.mux.HandleFunc("/receipts/{id}/points", api.GetPoints)
+	if api.logger == nil {
+		api.logger = slog.Default()
+		if api.errorLog != nil {
+			api.logger = slog.New(slog.NewTextHandler(api.errorLog.Writer(), nil))
+		}
+	}
+
+	if api.storeAttempts > 1 {
+		api.store = &retryStore{Store: api.store, attempts: api.storeAttempts, backoff: api.storeBackoff}
+	}
+
+	api.handler = api.mux
+
+	api.handle("/receipts", api.options(api.ListReceipts, "GET"))
+	api.handle("/receipts/process", api.options(api.writes(api.ProcessReceipt), "POST"))
+	api.handle("/receipts/process/stats", api.options(api.ProcessStats, "GET"))
+	api.handle("/receipts/{id}/points", api.methods(map[string]http.HandlerFunc{
+		"GET": api.GetPoints,
+		"PUT": api.writes(api.AdjustPoints),
+	}))
+	api.handle("/receipts/{id}/print", api.options(api.PrintReceipt, "GET"))
+	api.handle("/receipts/{id}/canonical", api.options(api.GetCanonicalReceipt, "GET"))
+	api.handle("/receipts/export.csv", api.options(api.ExportReceipts, "GET"))
+	api.handle("/receipts/import", api.options(api.writes(api.ImportReceipts), "POST"))
+	api.handle("/receipts/simulate", api.options(api.SimulatePoints, "POST"))
+	api.handle("/receipts/diff", api.options(api.DiffPoints, "POST"))
+	api.handle("/receipts/points:batch", api.options(api.BatchPoints, "POST"))
+	api.handle("/receipts/batch", api.options(api.writes(api.BatchProcessReceipts), "POST"))
+	api.handle("/receipts/{id}", api.methods(map[string]http.HandlerFunc{
+		"GET":    api.GetReceipt,
+		"PUT":    api.writes(api.UpdateReceipt),
+		"DELETE": api.writes(api.DeleteReceipt),
+	}))
+	api.handle("/leaderboard", api.options(api.Leaderboard, "GET"))
+	api.handle("/points/stats", api.options(api.PointsStats, "GET"))
+	api.handle("/points/expiring", api.options(api.ExpiringPoints, "GET"))
+	api.handle("/ruleset", api.options(api.GetRuleset, "GET"))
+	api.handle("/version", api.options(api.Version, "GET"))
+	api.handle("/healthz", api.options(api.Health, "GET"))
+
+	if api.debug {
+		api.handle("/debug/errors", api.options(api.DebugErrors, "GET"))
+		api.handle("/debug/receipts/{id}/raw", api.options(api.DebugRawRequest, "GET"))
+	}
+
+	api.handle("/", api.NotFound)
 
 	return api
 }
 
-// ServeHTTP serves as the entrypoint of the API for an [http.Server].
-func (api *API) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	api.mux.ServeHTTP(rw, req)
-}
+// NewAPIWithReceipts creates a new Fetch API configured with the given options
+// with its store preloaded with the receipts, e.g. to start tests or demos
+// from a known dataset. The receipts are stored with their IDs and points as
+// given, without recalculation, and marked scored so their points are served
+// and exported as given. Receipts not already scored are recorded as
+// preloaded in their points history. Receipts must be non-nil and have unique IDs
+// which match the ID pattern of the API, see [WithIDPattern], and do not exist
+// in the store.
+func NewAPIWithReceipts(receipts []*Receipt, opts ...Option) (*API, error) {
+	api := NewAPI(opts...)
 
-// Error writes the HTTP response with the given status and message in the
-// error response body.
-func (api *API) Error(rw http.ResponseWriter, status int, format string, args ...any) error {
-	rw.Header().Set("Content-Type", "application/json")
-	rw.WriteHeader(status)
+	for i, receipt := range receipts {
+		if receipt == nil {
+			return nil, fmt.Errorf("failed to preload receipt at index %d, receipt is nil", i)
+		}
+		if !api.idPattern.MatchString(receipt.ID) {
+			return nil, fmt.Errorf("failed to preload receipt %q, ID does not match the ID pattern", receipt.ID)
+		}
 
-	return json.NewEncoder(rw).Encode(&Error{
-		Message: fmt.Sprintf(format, args...),
+		if !receipt.Scored {
+			receipt = receipt.Clone()
+			receipt.PointsHistory = append(receipt.PointsHistory, PointsEvent{Time: api.now(), NewPoints: receipt.Points, Reason: "preloaded"})
+			receipt.Scored = true
+		}
+
+		if err := api.storePreserved(context.Background(), receipt); errors.Is(err, ErrExists) {
+			return nil, fmt.Errorf("failed to preload receipt %q, duplicate ID", receipt.ID)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to preload receipt %q, %w", receipt.ID, err)
+		}
+	}
+
+	return api, nil
+}
+
+// handle registers the handler for the given pattern, recording the pattern in
+// the request context so that the endpoint handling a request can be
+// identified, e.g. by [API.WriteError].
+func (api *API) handle(pattern string, handler http.HandlerFunc) {
+	api.mux.HandleFunc(pattern, func(rw http.ResponseWriter, req *http.Request) {
+		handler(rw, req.WithContext(context.WithValue(req.Context(), patternKey{}, pattern)))
 	})
 }
 
-// ProcessReceipt is an [http.HandlerFunc] that receives a request representing
-// a receipt, processes the receipt, assigns its point value, and stores the
-// receipt in non-durable storage for retrieval.
-func (api *API) ProcessReceipt(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != "POST" {
-		api.Error(rw, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
-		return
+// patternKey is the request context key of the pattern of the endpoint
+// handling the request.
+type patternKey struct{}
+
+// Use registers middleware wrapping all API endpoints. Middleware is applied in
+// the order registered, the first registered middleware being the outermost
+// and seeing requests first.
+//
+// Use must not be called concurrently with [API.ServeHTTP].
+func (api *API) Use(mw ...func(http.Handler) http.Handler) {
+	api.middleware = append(api.middleware, mw...)
+
+	var handler http.Handler = api.mux
+	for i := len(api.middleware) - 1; i >= 0; i-- {
+		handler = api.middleware[i](handler)
 	}
 
-	var prreq ProcessReceiptRequest
-	if err := json.NewDecoder(req.Body).Decode(&prreq); err != nil {
-		api.Error(rw, http.StatusBadRequest, "failed to parse process receipt request, %v", err)
+	api.handler = handler
+}
+
+// ServeHTTP serves as the entrypoint of the API for an [http.Server].
+//
+// Once [API.Close] has been called the API responds to all requests with `503
+// Service Unavailable`.
+//
+// Panics while serving a request, including in middleware, are recovered and
+// respond with `500 Internal Server Error`. Trailing slashes in request paths
+// are ignored.
+func (api *API) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rw := &trackingResponseWriter{ResponseWriter: w}
+	defer api.recoverPanic(rw, req)
+
+	api.closeMu.RLock()
+	if api.closed {
+		api.closeMu.RUnlock()
+		api.errorWithCode(rw, req, http.StatusServiceUnavailable, "shutting_down", "server is shutting down")
 		return
 	}
+	api.inflight.Add(1)
+	api.closeMu.RUnlock()
 
-	receipt, err := receiptFrom(&prreq)
-	if err != nil {
-		api.Error(rw, http.StatusBadRequest, "invalid process receipt request, %v", err)
-		return
+	defer api.inflight.Done()
+
+	api.handler.ServeHTTP(rw, trimTrailingSlash(req))
+}
+
+// trimTrailingSlash returns the request with any trailing slashes removed
+// from the path, so that e.g. `/receipts/process/` is handled the same as
+// `/receipts/process` rather than falling through to the not found handler.
+func trimTrailingSlash(req *http.Request) *http.Request {
+	path := req.URL.Path
+	if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+		return req
+	}
+
+	u := *req.URL
+	u.Path = strings.TrimRight(path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	if u.Path == "" {
+		u.Path = "/"
 	}
 
-	api.mu.Lock()
-	api.receipts[receipt.ID] = receipt
-	api.mu.Unlock()
+	trimmed := *req
+	trimmed.URL = &u
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(&ProcessReceiptResponse{
-		ID: receipt.ID,
-	})
+	return &trimmed
 }
 
-// GetPoints is an [http.HandlerFunc] that returns the point value for a receipt
-// specified by the `id` path parameter.
+// Close stops the API from accepting new requests and waits for in-flight
+// requests to finish. If the context is done before in-flight requests finish
+// Close returns the context error, leaving the remaining requests to finish in
+// the background.
 //
-// If no receipt exists for the given `id` the endpoint responds with `404 Not
-// Found`.
-func (api *API) GetPoints(rw http.ResponseWriter, req *http.Request) {
-	if req.Method != "GET" {
-		api.Error(rw, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
-		return
+// Close does not close the underlying store.
+func (api *API) Close(ctx context.Context) error {
+	api.closeMu.Lock()
+	api.closed = true
+	api.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		api.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for in-flight requests, %w", ctx.Err())
 	}
+}
 
-	id := req.PathValue("id")
-	if id == "" {
-		api.Error(rw, http.StatusBadRequest, "missing receipt ID")
-		return
+// modify calls fn with a copy of the stored receipt with the given ID and
+// saves the modified copy, returning it. Modifications are serialized so that
+// concurrent modifications of the same receipt are not lost. fn is called
+// again with a new copy if the modification is retried.
+func (api *API) modify(ctx context.Context, id string, fn func(receipt *Receipt)) (*Receipt, error) {
+	var receipt *Receipt
+	err := api.locked(ctx, func(ctx context.Context) error {
+		existing, err := api.store.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing.Deleted {
+			return errDeleted
+		}
+
+		// Copy the receipt rather than modifying it in place since readers
+		// may still hold a reference to it.
+		receipt = existing.Clone()
+
+		fn(receipt)
+
+		return api.store.Save(ctx, receipt)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	api.mu.RLock()
-	receipt, ok := api.receipts[id]
-	api.mu.RUnlock()
+	return receipt, nil
+}
 
-	if !ok {
-		api.Error(rw, http.StatusNotFound, "no receipt with ID %q exists", id)
-		return
+// Receipt returns a copy of the stored receipt with the given ID, and whether
+// it exists, for in-process use of the API, e.g. in tests or admin tooling.
+// Soft-deleted receipts are returned with [Receipt.Deleted] set. The returned
+// receipt may be modified without affecting the stored receipt.
+func (api *API) Receipt(id string) (*Receipt, bool) {
+	receipt, err := api.store.Get(context.Background(), id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			api.logger.Error("store failure", "receipt", id, "error", err)
+		}
+		return nil, false
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(&GetPointsResponse{
-		Points: receipt.Points,
+	return receipt.Clone(), true
+}
+
+// points returns the points of the stored receipt, recalculated using the
+// current ruleset if the API is configured with [WithAlwaysRecalculate].
+func (api *API) points(receipt *Receipt) int {
+	if api.alwaysRecalculate {
+		return api.ruleset.calculatePoints(receipt)
+	}
+
+	return receipt.Points
+}
+
+// errDeleted is returned when operating on a soft-deleted receipt.
+var errDeleted = errors.New("receipt deleted")
+
+// errHashExists is returned by storeNew when a receipt with the same content
+// hash already exists.
+var errHashExists = errors.New("receipt with content hash already exists")
+
+// storeNew stores a newly processed receipt, awarding the new customer bonus
+// if this is the first receipt of the user and recording the content hash of
+// the receipt. If onlyIfNew is true and a receipt with the same content hash
+// exists errHashExists is returned and the receipt is not stored.
+func (api *API) storeNew(ctx context.Context, receipt *Receipt, onlyIfNew bool) error {
+	return api.locked(ctx, func(ctx context.Context) error {
+		// Store a copy so that a retry starts from the receipt as given,
+		// e.g. without the new customer bonus already awarded.
+		attempt := receipt.Clone()
+
+		hash := attempt.ContentHash
+
+		if err := api.loadIndex(ctx); err != nil {
+			return err
+		}
+
+		_, exists := api.hashes[hash]
+		if exists && onlyIfNew {
+			return errHashExists
+		}
+
+		// Award the new customer bonus if this is the first receipt of the user.
+		if attempt.UserID != "" && api.ruleset.NewCustomerBonus > 0 {
+			prior, err := api.hasPriorReceipt(ctx, attempt.UserID)
+			if err != nil {
+				return err
+			}
+			if !prior {
+				api.awardNewCustomerBonus(attempt, "new customer bonus")
+			}
+		}
+
+		release, err := api.reserveQuota(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := api.createReceipt(ctx, attempt); err != nil {
+			release()
+			return err
+		}
+
+		api.index(attempt)
+		*receipt = *attempt
+
+		return nil
 	})
 }
 
-// receiptFrom creates a new [Receipt] from the [ProcessReceiptRequest].
-func receiptFrom(req *ProcessReceiptRequest) (*Receipt, error) {
-	receipt, err := NewReceipt()
+// hasPriorReceipt reports whether the user has a stored receipt, including
+// deleted receipts, so that the new customer bonus is awarded once per user.
+// Must be called while holding mu.
+func (api *API) hasPriorReceipt(ctx context.Context, userID string) (bool, error) {
+	if err := api.loadIndex(ctx); err != nil {
+		return false, err
+	}
+
+	_, prior := api.users[userID]
+
+	return prior, nil
+}
+
+// loadIndex loads the content hashes and users of the receipts in the store
+// once, so that duplicates and prior receipts are found after a restart or
+// when the store is shared with other instances. Receipts stored by the API
+// since are indexed as they are stored, see index. Must be called while
+// holding mu.
+func (api *API) loadIndex(ctx context.Context) error {
+	if api.indexed {
+		return nil
+	}
+
+	receipts, err := api.store.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create receipt, %w", err)
+		return err
 	}
 
-	receipt.Retailer = req.Retailer
+	// Index in ID order so that the owner of a hash shared by several
+	// receipts does not depend on the order of the store.
+	slices.SortFunc(receipts, func(a, b *Receipt) int { return strings.Compare(a.ID, b.ID) })
+	for _, receipt := range receipts {
+		api.index(receipt)
+	}
+	api.indexed = true
+
+	return nil
+}
 
-	if receipt.Purchased, err = parsePurchased(req.PurchaseDate, req.PurchaseTime); err != nil {
-		return nil, fmt.Errorf("invalid purchase date/time, %w", err)
+// index records the content hash of the stored receipt, unless it is deleted
+// or the hash is already recorded for another receipt, and its user, if any,
+// as having a prior receipt. Must be called while holding mu.
+func (api *API) index(receipt *Receipt) {
+	if _, exists := api.hashes[receipt.ContentHash]; receipt.ContentHash != "" && !receipt.Deleted && !exists {
+		api.hashes[receipt.ContentHash] = receipt.ID
+	}
+	if receipt.UserID != "" {
+		api.users[receipt.UserID] = struct{}{}
 	}
+}
 
-	for _, item := range req.Items {
-		price, err := parseAmount(item.Price)
-		if err != nil {
-			return nil, fmt.Errorf("invalid item price %q, %w", item.Price, err)
+// unindexHash releases the content hash of the deleted receipt, so that the
+// receipt may be processed again. Must be called while holding mu.
+func (api *API) unindexHash(receipt *Receipt) {
+	if receipt.ContentHash != "" && api.hashes[receipt.ContentHash] == receipt.ID {
+		delete(api.hashes, receipt.ContentHash)
+	}
+}
+
+// awardNewCustomerBonus marks the receipt as the first receipt of a new
+// customer and recalculates its points including the bonus, recording the
+// change with the reason in the points history.
+func (api *API) awardNewCustomerBonus(receipt *Receipt, reason string) {
+	receipt.NewCustomer = true
+
+	points, breakdown := api.ruleset.CalculatePointsWithBreakdown(receipt)
+	if api.persistBreakdowns {
+		receipt.Breakdown = breakdown
+	}
+
+	if slices.ContainsFunc(breakdown, func(rule RulePoints) bool { return rule.Rule == pointsCapRule }) {
+		reason = fmt.Sprintf("%s, capped at %d points", reason, points)
+	}
+
+	receipt.SetPoints(points, reason, api.now())
+}
+
+// maxCreateAttempts is the maximum number of IDs generated for a new receipt
+// before giving up on ID collisions.
+const maxCreateAttempts = 3
+
+// createReceipt stores the new receipt, generating a new ID for the receipt
+// if its ID collides with an existing receipt, and setting the expiry of its
+// points if points expire.
+func (api *API) createReceipt(ctx context.Context, receipt *Receipt) error {
+	if api.pointsTTL > 0 && receipt.PointsExpireAt.IsZero() {
+		receipt.PointsExpireAt = api.now().UTC().Add(api.pointsTTL)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := api.store.Create(ctx, receipt)
+		if !errors.Is(err, ErrExists) || attempt == maxCreateAttempts {
+			return err
 		}
 
-		receipt.Items = append(receipt.Items, ReceiptItem{
-			Description: item.ShortDescription,
-			Price:       price,
-		})
+		if receipt.ID, err = api.newID(); err != nil {
+			return fmt.Errorf("failed to generate receipt ID, %w", err)
+		}
 	}
+}
 
-	if receipt.Total, err = parseAmount(req.Total); err != nil {
-		return nil, fmt.Errorf("invalid receipt total %q, %w", receipt.Total, err)
+// receiptID returns the receipt ID from the `id` path parameter, responding
+// with an error and returning false if the ID is missing or does not match the
+// accepted ID format.
+//
+// If the handler was not routed by the API, e.g. it is mounted directly under
+// a different router, there is no `id` path parameter so the ID is extracted
+// from the request path instead. See [pathReceiptID].
+func (api *API) receiptID(rw http.ResponseWriter, req *http.Request) (string, bool) {
+	id := req.PathValue("id")
+	if id == "" {
+		id = pathReceiptID(req.URL.Path)
+	}
+	if id == "" {
+		api.errorWithCode(rw, req, http.StatusBadRequest, "missing_receipt_id", "missing receipt ID")
+		return "", false
 	}
 
-	receipt.Points = CalculatePoints(receipt)
+	// An ID that does not match the accepted format can not exist.
+	if !api.idPattern.MatchString(id) {
+		api.storeError(rw, req, id, ErrNotFound)
+		return "", false
+	}
 
-	return receipt, nil
+	return id, true
 }
 
-// parsePurchased parses date strings in the format "2006-01-02" and 24-hour
-// time strings in the format "13:30" and converts them into a single
-// [time.Time] representation.
-func parsePurchased(purchaseDate, purchaseTime string) (time.Time, error) {
-	purchased, err := time.Parse("2006-01-02", purchaseDate)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse purchase date %q, %w", purchaseDate, err)
+// pathReceiptID returns the receipt ID from a request path of the form
+// `/receipts/{id}` or `/receipts/{id}/points`, possibly under a prefix, e.g.
+// `/api/receipts/{id}/points`, or the empty string if the path has no ID.
+func pathReceiptID(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := len(segments) - 2; i >= 0; i-- {
+		if segments[i] == "receipts" {
+			return segments[i+1]
+		}
 	}
 
-	var hours, minutes int
-	if _, err := fmt.Sscanf(purchaseTime, "%d:%d", &hours, &minutes); err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse purchase time %q, %w", purchaseTime, err)
+	return ""
+}
+
+// storeError writes the HTTP response for an error returned from the store
+// while operating on the receipt with the given ID. [ErrNotFound] results in a
+// `404 Not Found`, errDeleted results in a `410 Gone`, and any other error is
+// logged and results in a `500 Internal Server Error`.
+func (api *API) storeError(rw http.ResponseWriter, req *http.Request, id string, err error) {
+	if errors.Is(err, ErrNotFound) {
+		api.errorWithCode(rw, req, http.StatusNotFound, "receipt_not_found", "no receipt with ID %q exists", id)
+		return
+	}
+	if errors.Is(err, errDeleted) {
+		api.errorWithCode(rw, req, http.StatusGone, "receipt_deleted", "receipt with ID %q has been deleted", id)
+		return
 	}
 
-	if hours < 0 || hours > 23 {
-		return time.Time{}, fmt.Errorf("invalid hour value '%d', must be >= 0 and <= 23", hours)
+	api.logger.ErrorContext(req.Context(), "store failure", "receipt", id, "error", err)
+	api.WriteError(rw, req, http.StatusInternalServerError, "failed to access receipt %q", id)
+}
+
+// methods returns an [http.HandlerFunc] that dispatches requests to the handler
+// registered for the request method, responding with `405 Method Not Allowed`
+// for any other method. `OPTIONS` requests are answered as by [API.options].
+func (api *API) methods(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
 	}
-	if minutes < 0 || minutes > 59 {
-		return time.Time{}, fmt.Errorf("invalid minute value '%d', must be >= 0 and <= 59", hours)
+	sort.Strings(methods)
+
+	allowed := make([]string, 0, len(methods))
+	for _, method := range methods {
+		allowed = append(allowed, fmt.Sprintf("'%s'", method))
 	}
 
-	purchased = purchased.
-		Add(time.Duration(hours) * time.Hour).
-		Add(time.Duration(minutes) * time.Minute)
+	return api.options(func(rw http.ResponseWriter, req *http.Request) {
+		handler, ok := handlers[req.Method]
+		if !ok {
+			api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be one of %s", strings.Join(allowed, ", "))
+			return
+		}
 
-	return purchased, nil
+		handler(rw, req)
+	}, methods...)
 }
 
-// parseAmount parses a string representing a money value and converts it to an
-// integer representing the value as cents, e.g. "67.10" to 6710.
-func parseAmount(amount string) (int, error) {
-	var dollars, cents int
-	if _, err := fmt.Sscanf(amount, "%d.%d", &dollars, &cents); err != nil {
-		return 0, fmt.Errorf("failed to parse amount %q, %w", amount, err)
+// options returns an [http.HandlerFunc] that responds to `OPTIONS` requests
+// with `204 No Content` and an `Allow` header listing the given methods of the
+// endpoint along with `OPTIONS`, passing all other requests to the handler.
+func (api *API) options(handler http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allow := strings.Join(append(slices.Clone(methods), "OPTIONS"), ", ")
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "OPTIONS" {
+			handler(rw, req)
+			return
+		}
+
+		rw.Header().Set("Allow", allow)
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// encoder returns a JSON encoder for writing the response body, shared by all
+// endpoints. The output is indented with two spaces if the `pretty` query
+// parameter of the request is set to `true`, to aid debugging.
+func (api *API) encoder(w io.Writer, req *http.Request) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if req.URL.Query().Get("pretty") == "true" {
+		enc.SetIndent("", "  ")
+	}
+
+	return enc
+}
+
+// Error writes the HTTP response with the given status and message in the
+// JSON error response body, including the machine-readable code of the first
+// error in args with a known code, e.g. "invalid_amount" for
+// [ErrInvalidAmount], otherwise a generic code for the status, e.g.
+// "not_found". See [API.WriteError] to respond to a specific request.
+func (api *API) Error(rw http.ResponseWriter, status int, format string, args ...any) error {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	return json.NewEncoder(rw).Encode(&Error{
+		Message: fmt.Sprintf(format, args...),
+		Code:    responseErrorCode(status, "", args),
+	})
+}
+
+// WriteError writes the HTTP response to the request as [API.Error], recording
+// the error as the last error of the endpoint handling the request when the
+// API is configured with [WithDebug].
+//
+// The error response body is JSON unless the `Accept` header of the request
+// prefers `text/plain`, in which case the body is the plain message.
+func (api *API) WriteError(rw http.ResponseWriter, req *http.Request, status int, format string, args ...any) error {
+	return api.errorWithCode(rw, req, status, "", format, args...)
+}
+
+// errorWithCode writes the HTTP response as [API.WriteError] with the given
+// error code, or the code derived from args and status as [API.Error] if
+// empty.
+func (api *API) errorWithCode(rw http.ResponseWriter, req *http.Request, status int, code string, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	code = responseErrorCode(status, code, args)
+
+	api.recordError(req, status, msg)
+
+	if prefersPlainText(req.Header.Get("Accept")) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rw.WriteHeader(status)
+
+		_, err := fmt.Fprintln(rw, msg)
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	return api.encoder(rw, req).Encode(&Error{
+		Message: msg,
+		Code:    code,
+	})
+}
+
+// responseErrorCode returns the code of an error response, the given code if
+// set, otherwise the code of the first error in args with a known code, or
+// the generic code for the status.
+func responseErrorCode(status int, code string, args []any) string {
+	if code == "" {
+		code = errorCode(args)
+	}
+	if code == "" {
+		code = statusErrorCodes[status]
+	}
+
+	return code
+}
+
+// prefersPlainText reports whether the Accept header prefers `text/plain` over
+// `application/json`, by the quality values of the most specific matching
+// media ranges. JSON is preferred if both are equally acceptable.
+func prefersPlainText(accept string) bool {
+	if accept == "" {
+		return false
+	}
+
+	// quality returns the quality value of the most specific media range in
+	// the Accept header matching the media type.
+	quality := func(mediaType string) float64 {
+		typ, _, _ := strings.Cut(mediaType, "/")
+
+		q, specificity := 0.0, -1
+		for _, part := range strings.Split(accept, ",") {
+			mediaRange, params, _ := strings.Cut(part, ";")
+			mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+			var s int
+			switch mediaRange {
+			case mediaType:
+				s = 2
+			case typ + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			default:
+				continue
+			}
+			if s < specificity {
+				continue
+			}
+
+			rangeQ := 1.0
+			for _, param := range strings.Split(params, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(name, "q") {
+					if v, err := strconv.ParseFloat(value, 64); err == nil {
+						rangeQ = v
+					}
+				}
+			}
+
+			q, specificity = rangeQ, s
+		}
+
+		return q
+	}
+
+	return quality("text/plain") > quality("application/json")
+}
+
+// NotFound is an [http.HandlerFunc] that responds with `404 Not Found` for
+// requests that do not match any API endpoint.
+func (api *API) NotFound(rw http.ResponseWriter, req *http.Request) {
+	api.errorWithCode(rw, req, http.StatusNotFound, "endpoint_not_found", "no endpoint exists for path %q", req.URL.Path)
+}
+
+// ContentHashHeader is the request header used by clients to supply the
+// expected content hash of the receipt to the [ProcessReceipt] endpoint, and
+// the response header carrying it from the [GetCanonicalReceipt] endpoint.
+const ContentHashHeader = "X-Content-Hash"
+
+// ProcessReceipt is an [http.HandlerFunc] that receives a request representing
+// a receipt, processes the receipt, assigns its point value, and stores the
+// receipt in non-durable storage for retrieval.
+//
+// The server hashes the [CanonicalJSON] of each receipt with the hash function
+// of the API, see [WithHash]. Clients may send `If-None-Match: *` to only
+// create the receipt if no receipt with the same content hash exists,
+// otherwise the endpoint responds with `412 Precondition Failed`. Clients may
+// also supply the content hash they computed in the [ContentHashHeader]
+// header, and the endpoint responds with `400 Bad Request` if it does not
+// match the content hash computed by the server.
+func (api *API) ProcessReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	if api.processSlots != nil {
+		select {
+		case api.processSlots <- struct{}{}:
+			defer func() { <-api.processSlots }()
+		default:
+			rw.Header().Set("Retry-After", "1")
+			api.errorWithCode(rw, req, http.StatusServiceUnavailable, "too_many_receipts", "too many receipts being processed, retry later")
+			return
+		}
+	}
+
+	ifNoneMatch := req.Header.Get("If-None-Match")
+
+	if ifNoneMatch != "" && ifNoneMatch != "*" {
+		api.countFailure("invalid_if_none_match")
+		api.WriteError(rw, req, http.StatusBadRequest, "invalid If-None-Match header %q, must be '*'", ifNoneMatch)
+		return
+	}
+
+	timing := api.newServerTiming(rw.Header())
+
+	body, capture := api.captureRaw(req.Body)
+
+	var prreq ProcessReceiptRequest
+	if err := api.decodeReceipt(body, &prreq); err != nil {
+		api.countFailure("malformed_request")
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse process receipt request, %v", err)
+		return
+	}
+
+	var (
+		raw          []byte
+		rawTruncated bool
+	)
+	if capture != nil {
+		raw, rawTruncated = capture.finish(body)
+	}
+
+	if receiptURL := prreq.ReceiptURL; receiptURL != "" {
+		prreq = ProcessReceiptRequest{}
+
+		var urlErr *errReceiptURL
+		if err := api.fetchReceipt(req.Context(), receiptURL, &prreq); errors.As(err, &urlErr) {
+			api.countFailure("invalid_receipt_url")
+			api.WriteError(rw, req, http.StatusBadRequest, "invalid process receipt request, %v", err)
+			return
+		} else if err != nil {
+			api.countFailure("receipt_url_unavailable")
+			api.WriteError(rw, req, http.StatusBadGateway, "failed to fetch receipt from receiptUrl, %v", err)
+			return
+		}
+	}
+	timing.mark("decode")
+
+	receipt, warnings, err := api.receiptFrom(&prreq, timing)
+	if err != nil {
+		api.countFailure(receiptErrorReason(err))
+		api.WriteError(rw, req, receiptErrorStatus(err), "invalid process receipt request, %v", err)
+		return
+	}
+
+	hash, err := api.contentHash(receipt)
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to hash receipt", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to hash receipt")
+		return
+	}
+	if supplied := req.Header.Get(ContentHashHeader); supplied != "" && supplied != hash {
+		api.countFailure("content_hash_mismatch")
+		api.WriteError(rw, req, http.StatusBadRequest, "invalid %s header %q, does not match content hash %q of the receipt", ContentHashHeader, supplied, hash)
+		return
+	}
+
+	receipt.ContentHash = hash
+	receipt.RawRequest = raw
+	receipt.RawRequestTruncated = rawTruncated
+
+	err = api.storeNew(req.Context(), receipt, ifNoneMatch == "*")
+	timing.mark("store")
+
+	var quotaErr *quotaExceededError
+	if errors.As(err, &quotaErr) {
+		api.quotaError(rw, req, quotaErr)
+		return
+	}
+	if errors.Is(err, errHashExists) {
+		api.countFailure("content_hash_exists")
+		api.errorWithCode(rw, req, http.StatusPreconditionFailed, "content_hash_exists", "receipt with content hash %q already exists", hash)
+		return
+	}
+	if err != nil {
+		api.countFailure("store_failure")
+		api.storeError(rw, req, receipt.ID, err)
+		return
+	}
+
+	api.processed.succeeded.Add(1)
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&ProcessReceiptResponse{
+		ID:       receipt.ID,
+		Warnings: warnings,
+	})
+}
+
+// GetPoints is an [http.HandlerFunc] that returns the point value for a receipt
+// specified by the `id` path parameter.
+//
+// If the `history` query parameter is set to `true` the response includes the
+// audit trail of changes to the points assigned to the receipt.
+//
+// The response body is JSON unless the `Accept` header of the request prefers
+// `text/plain`, in which case the body is the bare integer point value, e.g.
+// for clients unable to parse JSON.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`, or `410 Gone` if the receipt has been deleted.
+func (api *API) GetPoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := api.store.Get(req.Context(), id)
+	if err == nil && receipt.Deleted {
+		err = errDeleted
+	}
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	if prefersPlainText(req.Header.Get("Accept")) {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(rw, api.points(receipt))
+		return
+	}
+
+	resp := GetPointsResponse{
+		Points: api.points(receipt),
+		Scored: receipt.Scored || api.alwaysRecalculate,
+	}
+
+	if req.URL.Query().Get("history") == "true" {
+		for _, event := range receipt.PointsHistory {
+			resp.History = append(resp.History, PointsEventResponse{
+				Time:      event.Time.UTC().Format(time.RFC3339),
+				OldPoints: event.OldPoints,
+				NewPoints: event.NewPoints,
+				Reason:    event.Reason,
+			})
+		}
+	}
+
+	api.encodePoints(rw, req, &resp)
+}
+
+// AdjustPoints is an [http.HandlerFunc] that manually assigns the point value
+// for the receipt specified by the `id` path parameter, e.g. in the case of
+// fraud, returns, or customer satisfaction. The adjustment and its reason are
+// recorded in the points history of the receipt.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`.
+func (api *API) AdjustPoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "PUT" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'PUT'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	var apreq AdjustPointsRequest
+	if err := api.decode(req.Body, &apreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse adjust points request, %v", err)
+		return
+	}
+
+	if strings.TrimSpace(apreq.Reason) == "" {
+		api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid adjust points request, missing reason")
+		return
+	}
+	if apreq.Points < 0 {
+		api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid adjust points request, points must not be negative")
+		return
+	}
+
+	receipt, err := api.modify(req.Context(), id, func(receipt *Receipt) {
+		receipt.SetPoints(apreq.Points, apreq.Reason, api.now())
+	})
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	api.encodePoints(rw, req, &GetPointsResponse{
+		Points: receipt.Points,
+		Scored: receipt.Scored,
+	})
+}
+
+// GetReceipt is an [http.HandlerFunc] that returns the receipt specified by the
+// `id` path parameter.
+//
+// If the `explain` query parameter is set to `true` the response includes the
+// breakdown of the points by rule, which may differ from the points assigned
+// to the receipt if they have been adjusted.
+//
+// If the `fields` query parameter is set the response includes only the given
+// comma separated top-level fields, e.g. `?fields=id,points`. Unknown field
+// names respond with `400 Bad Request`.
+//
+// The response includes an `ETag` header over the response body, which changes
+// when the receipt is edited or its points are adjusted, and differs between
+// representations of the receipt, e.g. with and without `explain`. If the
+// `If-None-Match` header of the request matches the ETag the endpoint
+// responds with `304 Not Modified`.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`, or `410 Gone` if the receipt has been deleted.
+func (api *API) GetReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	fields, err := parseFields(req.URL.Query().Get("fields"), receiptResponseFields)
+	if err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "invalid fields, %v", err)
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := api.store.Get(req.Context(), id)
+	if err == nil && receipt.Deleted {
+		err = errDeleted
+	}
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	resp := api.receiptResponseFrom(receipt)
+
+	if req.URL.Query().Get("explain") == "true" {
+		total, rules := api.breakdown(receipt)
+		resp.Explanation = &PointsExplanation{
+			Rules:     rules,
+			RuleTotal: total,
+			Adjusted:  total != resp.Points,
+		}
+	}
+
+	var body any = resp
+	if fields != nil {
+		selected, err := selectFields(resp, fields)
+		if err != nil {
+			api.logger.ErrorContext(req.Context(), "failed to select fields", "receipt", id, "error", err)
+			api.WriteError(rw, req, http.StatusInternalServerError, "failed to select fields of receipt with ID %q", id)
+			return
+		}
+		body = selected
+	}
+
+	// Encode the body before responding so that the ETag is of the body as
+	// sent, including the explanation and selected fields.
+	var buf bytes.Buffer
+	api.encoder(&buf, req).Encode(body)
+
+	etag := api.receiptETag(buf.Bytes())
+	rw.Header().Set("ETag", etag)
+
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(buf.Bytes())
+}
+
+// receiptETag returns the strong ETag of the encoded receipt response body, a
+// hash of the body using the hash function of the API.
+func (api *API) receiptETag(body []byte) string {
+	sum := api.hash(body)
+
+	return fmt.Sprintf(`"%x"`, sum[:min(len(sum), 16)])
+}
+
+// WithHash sets the hash function used by the API to hash receipts, for ETags
+// and the content hash of the [CanonicalJSON] of receipts which deduplicates
+// processed receipts, such as
+// [crypto/sha512.New] where SHA-512 is required. Defaults to
+// [crypto/sha256.New].
+//
+// WithHash panics if newHash is nil.
+func WithHash(newHash func() hash.Hash) Option {
+	if newHash == nil {
+		panic("fetch: WithHash requires a non-nil hash function")
+	}
+
+	return func(api *API) {
+		api.newHash = newHash
+	}
+}
+
+// hash returns the hash of b using the hash function of the API.
+func (api *API) hash(b []byte) []byte {
+	h := api.newHash()
+	h.Write(b)
+
+	return h.Sum(nil)
+}
+
+// etagMatches reports whether the `If-None-Match` header matches the ETag,
+// using the weak comparison required for `If-None-Match`.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// breakdown returns the points of the receipt by rule, using the breakdown
+// stored on the receipt when its points were calculated if any, otherwise
+// calculated under the current ruleset.
+func (api *API) breakdown(receipt *Receipt) (int, []RulePoints) {
+	if receipt.Breakdown == nil || api.alwaysRecalculate {
+		return api.ruleset.CalculatePointsWithBreakdown(receipt)
+	}
+
+	var total int
+	for _, rule := range receipt.Breakdown {
+		total += rule.Points
+	}
+
+	return total, receipt.Breakdown
+}
+
+// UpdateReceipt is an [http.HandlerFunc] that replaces the receipt specified by
+// the `id` path parameter with the receipt in the request body and returns the
+// recalculated point value.
+//
+// Since an update is an explicit edit of the receipt, points are always
+// recalculated from the updated receipt data.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`.
+func (api *API) UpdateReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "PUT" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'PUT'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := api.decodeReceipt(req.Body, &prreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse update receipt request, %v", err)
+		return
+	}
+
+	updated, _, err := api.receiptFrom(&prreq, nil)
+	if err != nil {
+		api.WriteError(rw, req, receiptErrorStatus(err), "invalid update receipt request, %v", err)
+		return
+	}
+
+	receipt, err := api.modify(req.Context(), id, func(existing *Receipt) {
+		// Keep the new customer bonus of the first receipt of the user.
+		if existing.NewCustomer {
+			api.awardNewCustomerBonus(updated, "new customer bonus")
+		}
+
+		// Carry over the points history, content hash, points expiry, and
+		// raw request of the existing receipt, recording the recalculation in
+		// place of the initial calculation.
+		history := append(existing.PointsHistory, PointsEvent{
+			Time:      api.now(),
+			OldPoints: existing.Points,
+			NewPoints: updated.Points,
+			Reason:    "recalculated after receipt update",
+		})
+
+		updated.ID = existing.ID
+		updated.ContentHash = existing.ContentHash
+		updated.PointsExpireAt = existing.PointsExpireAt
+		updated.RawRequest = existing.RawRequest
+		updated.RawRequestTruncated = existing.RawRequestTruncated
+		updated.PointsHistory = history
+
+		*existing = *updated
+		api.index(existing)
+	})
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	api.encodePoints(rw, req, &GetPointsResponse{
+		Points: receipt.Points,
+		Scored: receipt.Scored,
+	})
+}
+
+// DeleteReceipt is an [http.HandlerFunc] that deletes the receipt specified by
+// the `id` path parameter.
+//
+// By default receipts are soft-deleted, retaining them for reporting, unless
+// the API is configured with [WithHardDelete]. Either way the content hash of
+// the deleted receipt is released, so that resubmitting the receipt with the
+// `If-None-Match: *` header stores it again rather than being rejected as a
+// duplicate.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`, or `410 Gone` if the receipt has already been deleted.
+func (api *API) DeleteReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "DELETE" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'DELETE'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	var err error
+	if api.hardDelete {
+		err = api.deleteReceipt(req.Context(), id)
+	} else {
+		err = api.softDeleteReceipt(req.Context(), id)
+	}
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// deleteReceipt permanently removes the receipt with the given ID from the
+// store along with its content hash.
+func (api *API) deleteReceipt(ctx context.Context, id string) error {
+	return api.locked(ctx, func(ctx context.Context) error {
+		receipt, err := api.store.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := api.store.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		api.unindexHash(receipt)
+
+		return nil
+	})
+}
+
+// softDeleteReceipt marks the receipt with the given ID as deleted, retaining
+// it in the store, and releases its content hash so that the receipt may be
+// processed again.
+func (api *API) softDeleteReceipt(ctx context.Context, id string) error {
+	return api.locked(ctx, func(ctx context.Context) error {
+		existing, err := api.store.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing.Deleted {
+			return errDeleted
+		}
+
+		receipt := existing.Clone()
+		receipt.Deleted = true
+		receipt.DeletedAt = api.now()
+
+		if err := api.store.Save(ctx, receipt); err != nil {
+			return err
+		}
+
+		api.unindexHash(receipt)
+
+		return nil
+	})
+}
+
+// ExportReceipts is an [http.HandlerFunc] that streams all stored receipts as
+// CSV with one row per receipt, ordered by ID. Soft-deleted receipts are
+// excluded unless the `includeDeleted` query parameter is set to `true`.
+//
+// The receipts are snapshotted before streaming begins so that receipts
+// processed during the export do not affect it.
+func (api *API) ExportReceipts(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	receipts, err := api.listReceipts(req)
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to list receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+
+	clearWriteDeadline(rw)
+
+	rw.Header().Set("Content-Type", "text/csv")
+	rw.Header().Set("Transfer-Encoding", "chunked")
+
+	w := csv.NewWriter(rw)
+	w.Write([]string{"id", "retailer", "purchaseDate", "purchaseTime", "total", "points"})
+
+	streamReceipts(rw, receipts, func(_ int, receipt *Receipt) {
+		w.Write([]string{
+			receipt.ID,
+			receipt.Retailer,
+			receipt.Purchased.Format("2006-01-02"),
+			receipt.Purchased.Format("15:04"),
+			formatAmount(receipt.Total),
+			strconv.Itoa(api.points(receipt)),
+		})
+	}, w.Flush)
+
+	w.Flush()
+}
+
+// listReceipts returns a snapshot of the stored receipts ordered by ID.
+// Soft-deleted receipts are excluded unless the `includeDeleted` query
+// parameter of the request is set to `true`.
+func (api *API) listReceipts(req *http.Request) ([]*Receipt, error) {
+	receipts, err := api.store.List(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL.Query().Get("includeDeleted") != "true" {
+		receipts = slices.DeleteFunc(receipts, func(receipt *Receipt) bool {
+			return receipt.Deleted
+		})
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].ID < receipts[j].ID
+	})
+
+	return receipts, nil
+}
+
+// clearWriteDeadline clears the write deadline of the response, e.g. set by
+// the WriteTimeout of the [http.Server], so that streamed responses are not
+// cut off once they take longer than the timeout. Response writers which do
+// not support deadlines, e.g. [httptest.ResponseRecorder], are left as is.
+func clearWriteDeadline(rw http.ResponseWriter) {
+	http.NewResponseController(rw).SetWriteDeadline(time.Time{})
+}
+
+// maxUTCOffset is the largest offset from UTC of any timezone. Purchase times
+// are not captured with a timezone, so a receipt may appear to be up to this
+// far in the future when compared to the current time in UTC.
+const maxUTCOffset = 14 * time.Hour
+
+// validationError is an error returned when a request is well-formed but
+// semantically invalid, e.g. a purchase date in the future.
+type validationError struct {
+	msg string
+}
+
+// invalidf creates a new [validationError] with the formatted message.
+func invalidf(format string, args ...any) error {
+	return &validationError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *validationError) Error() string {
+	return e.msg
+}
+
+// receiptErrorStatus returns the HTTP status code for an error returned from
+// receiptFrom. Semantically invalid receipts result in `422 Unprocessable
+// Entity` while malformed receipts result in `400 Bad Request`.
+func receiptErrorStatus(err error) int {
+	var verr *validationError
+	if errors.As(err, &verr) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusBadRequest
+}
+
+// receiptFrom creates a new [Receipt] from the [ProcessReceiptRequest] and
+// assigns its point value using the ruleset of the API, returning any
+// warnings about the request. The validate and score phases are recorded by
+// the timing, which may be nil.
+func (api *API) receiptFrom(req *ProcessReceiptRequest, timing *serverTiming) (*Receipt, []string, error) {
+	receipt, warnings, err := api.parseReceipt(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := api.ruleset.accept(receipt); err != nil {
+		return nil, nil, err
+	}
+	timing.mark("validate")
+
+	api.score(receipt)
+	timing.mark("score")
+
+	return receipt, warnings, nil
+}
+
+// score assigns the initial point value of a newly parsed receipt using the
+// ruleset of the API, persisting the breakdown of the points by rule if so
+// configured. Capped points are noted in the points history of the receipt.
+// Receipts which already have points assigned are left unchanged.
+func (api *API) score(receipt *Receipt) {
+	if receipt.Scored || receipt.Points > 0 {
+		return
+	}
+
+	points, breakdown := api.ruleset.CalculatePointsWithBreakdown(receipt)
+	if api.persistBreakdowns {
+		receipt.Breakdown = breakdown
+	}
+
+	reason := "initial calculation"
+	if slices.ContainsFunc(breakdown, func(rule RulePoints) bool { return rule.Rule == pointsCapRule }) {
+		reason = fmt.Sprintf("initial calculation, capped at %d points", points)
+	}
+
+	receipt.SetPoints(points, reason, api.now())
+}
+
+// parseReceipt creates a new [Receipt] from the [ProcessReceiptRequest] without
+// assigning its point value, along with warnings describing any non-fatal
+// issues with the request that were forgiven.
+func (api *API) parseReceipt(req *ProcessReceiptRequest) (*Receipt, []string, error) {
+	var warnings []string
+
+	// amount strips any currency symbol from the amount, if so configured,
+	// warning of the forgiven symbol.
+	amount := func(value, what string) string {
+		stripped, ok := stripCurrencySymbol(value)
+		if !ok || !api.stripCurrencySymbols {
+			return value
+		}
+
+		warnings = append(warnings, fmt.Sprintf("stripped currency symbol from %s %q", what, value))
+		return stripped
+	}
+
+	id, err := api.newID()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create receipt, %w", err)
+	}
+
+	receipt := &Receipt{ID: id}
+
+	receipt.Retailer = req.Retailer
+
+	purchaseTime := req.PurchaseTime
+	if purchaseTime == "" && req.PurchaseDate != "" {
+		purchaseTime = api.defaultPurchaseTime
+	}
+
+	// Validate the presence of the purchase date and time up front since a
+	// missing date or time otherwise results in a confusing parse error.
+	switch {
+	case req.PurchaseDate == "" && purchaseTime == "":
+		return nil, nil, fmt.Errorf("missing purchaseDate and purchaseTime")
+	case req.PurchaseDate == "":
+		return nil, nil, fmt.Errorf("missing purchaseDate, required with purchaseTime")
+	case purchaseTime == "":
+		return nil, nil, fmt.Errorf("missing purchaseTime, required with purchaseDate")
+	}
+
+	if receipt.Purchased, err = parsePurchased(api.dateLayouts, req.PurchaseDate, purchaseTime); err != nil {
+		return nil, nil, fmt.Errorf("invalid purchase date/time, %w", err)
+	}
+
+	if receipt.Purchased.After(api.now().UTC().Add(maxUTCOffset)) {
+		return nil, nil, invalidf("purchase date/time %s %s is in the future", req.PurchaseDate, purchaseTime)
+	}
+
+	for i, item := range req.Items {
+		if n := len(strings.TrimSpace(item.ShortDescription)); n > api.maxDescriptionLength {
+			return nil, nil, fmt.Errorf("description of items[%d] has length %d, exceeds maximum of %d", i, n, api.maxDescriptionLength)
+		}
+
+		price, err := parseAmount(amount(item.Price, fmt.Sprintf("price of items[%d]", i)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid price of items[%d] %q, %w", i, item.ShortDescription, err)
+		}
+
+		receipt.Items = append(receipt.Items, ReceiptItem{
+			Description: item.ShortDescription,
+			Price:       price,
+		})
+	}
+
+	if receipt.Total, err = parseAmount(amount(req.Total, "total")); err != nil {
+		return nil, nil, fmt.Errorf("invalid receipt total %q, %w", req.Total, err)
+	}
+
+	if receipt.Total < 0 {
+		return nil, nil, invalidf("receipt total %q must not be negative", req.Total)
+	}
+
+	if receipt.Total > api.maxTotal {
+		return nil, nil, invalidf("receipt total %q exceeds maximum of %q", req.Total, formatAmount(api.maxTotal))
+	}
+
+	if api.totalTolerance >= 0 && len(receipt.Items) > 0 {
+		var sum int
+		for _, item := range receipt.Items {
+			sum += item.Price
+		}
+
+		if diff := receipt.Total - sum; diff > api.totalTolerance || -diff > api.totalTolerance {
+			return nil, nil, invalidf("receipt total %q does not match sum of item prices %q", req.Total, formatAmount(sum))
+		}
+	}
+
+	if req.Discount != "" {
+		if receipt.Discount, err = parseDiscount(amount(req.Discount, "discount")); err != nil {
+			return nil, nil, fmt.Errorf("invalid discount %q, %w", req.Discount, err)
+		}
+		if receipt.Discount.Percent < 0 || receipt.Discount.Amount < 0 {
+			return nil, nil, invalidf("discount %q must not be negative", req.Discount)
+		}
+		if receipt.Discount.Percent > 100 {
+			return nil, nil, invalidf("discount %q must not exceed 100%%", req.Discount)
+		}
+		if receipt.Discount.Amount > receipt.Total {
+			return nil, nil, invalidf("discount %q exceeds receipt total %q", req.Discount, req.Total)
+		}
+	}
+
+	if len(req.Metadata) > maxMetadataKeys {
+		return nil, nil, invalidf("metadata has %d keys, must have at most %d", len(req.Metadata), maxMetadataKeys)
+	}
+	for key, value := range req.Metadata {
+		if len(key) > maxMetadataKeyLength {
+			return nil, nil, invalidf("metadata key %q exceeds maximum length of %d", key, maxMetadataKeyLength)
+		}
+		if len(value) > maxMetadataValueLength {
+			return nil, nil, invalidf("metadata value of %q exceeds maximum length of %d", key, maxMetadataValueLength)
+		}
+	}
+
+	receipt.Metadata = maps.Clone(req.Metadata)
+
+	if len(req.UserID) > maxUserIDLength {
+		return nil, nil, invalidf("user ID exceeds maximum length of %d", maxUserIDLength)
+	}
+	receipt.UserID = req.UserID
+
+	return receipt, warnings, nil
+}
+
+// Errors returned for invalid amounts and purchase times, reported to clients
+// with the corresponding [Error.Code].
+var (
+	// ErrInvalidAmount is returned for amounts not in the format "15.30".
+	ErrInvalidAmount = errors.New("failed to parse amount")
+	// ErrInvalidDate is returned for purchase dates in none of the accepted
+	// date layouts.
+	ErrInvalidDate = errors.New("failed to parse purchase date")
+	// ErrTimeFormat is returned for purchase times not in the format "13:30".
+	ErrTimeFormat = errors.New("failed to parse purchase time")
+	// ErrInvalidHour is returned for purchase times with an hour out of range.
+	ErrInvalidHour = errors.New("invalid hour value")
+	// ErrInvalidMinute is returned for purchase times with a minute out of
+	// range.
+	ErrInvalidMinute = errors.New("invalid minute value")
+)
+
+// errorCodes are the codes of the errors reported to clients in
+// [Error.Code].
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{err: ErrInvalidAmount, code: "invalid_amount"},
+	{err: ErrInvalidDate, code: "invalid_date"},
+	{err: ErrTimeFormat, code: "invalid_time_format"},
+	{err: ErrInvalidHour, code: "invalid_hour"},
+	{err: ErrInvalidMinute, code: "invalid_minute"},
+}
+
+// statusErrorCodes are the codes of errors with no more specific code, by
+// response status.
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusNotFound:            "not_found",
+	http.StatusMethodNotAllowed:    "method_not_allowed",
+	http.StatusGone:                "gone",
+	http.StatusPreconditionFailed:  "precondition_failed",
+	http.StatusUnprocessableEntity: "validation_failed",
+	http.StatusTooManyRequests:     "too_many_requests",
+	http.StatusInternalServerError: "internal_error",
+	http.StatusBadGateway:          "bad_gateway",
+	http.StatusServiceUnavailable:  "unavailable",
+}
+
+// errorCode returns the code of the first error in args with a code, or the
+// empty string if there is none.
+func errorCode(args []any) string {
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+
+		for _, ec := range errorCodes {
+			if errors.Is(err, ec.err) {
+				return ec.code
+			}
+		}
+	}
+
+	return ""
+}
+
+// parsePurchased parses date strings in one of the date layouts, e.g.
+// "2006-01-02", and 24-hour time strings in the format "13:30" and converts
+// them into a single [time.Time] representation.
+func parsePurchased(layouts []string, purchaseDate, purchaseTime string) (time.Time, error) {
+	purchased, err := parseDate(layouts, purchaseDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	timeOfDay, err := parseTimeOfDay(purchaseTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return purchased.Add(timeOfDay), nil
+}
+
+// parseTimeOfDay parses the purchase time, in 24-hour time format e.g.
+// "14:30", into the duration since midnight.
+func parseTimeOfDay(purchaseTime string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(purchaseTime, "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("%w %q, %v", ErrTimeFormat, purchaseTime, err)
+	}
+
+	if hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("%w '%d', must be >= 0 and <= 23", ErrInvalidHour, hours)
+	}
+	if minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%w '%d', must be >= 0 and <= 59", ErrInvalidMinute, minutes)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// parseDate parses the date string using the first matching layout. Dates
+// matching multiple layouts with different results, e.g. "03/04/2024" with
+// both "01/02/2006" and "02/01/2006", are rejected as ambiguous.
+func parseDate(layouts []string, date string) (time.Time, error) {
+	var (
+		parsed   time.Time
+		matched  string
+		firstErr error
+	)
+
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, date)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if matched == "" {
+			parsed, matched = t, layout
+			continue
+		}
+
+		if !t.Equal(parsed) {
+			return time.Time{}, fmt.Errorf("ambiguous purchase date %q, matches layouts %q and %q", date, matched, layout)
+		}
+	}
+
+	if matched == "" {
+		return time.Time{}, fmt.Errorf("%w %q, %w", ErrInvalidDate, date, firstErr)
+	}
+
+	return parsed, nil
+}
+
+// parseDiscount parses a discount string, either a whole percentage e.g. "10%"
+// or a monetary value e.g. "1.50".
+func parseDiscount(discount string) (Discount, error) {
+	if percent, ok := strings.CutSuffix(discount, "%"); ok {
+		n, err := strconv.Atoi(percent)
+		if err != nil || strings.HasPrefix(percent, "+") {
+			return Discount{}, fmt.Errorf("percentage must be a whole number")
+		}
+		return Discount{Percent: n}, nil
+	}
+
+	amount, err := parseAmount(discount)
+	if err != nil {
+		return Discount{}, err
+	}
+
+	return Discount{Amount: amount}, nil
+}
+
+// stripCurrencySymbol removes the leading currency symbol of the amount, e.g.
+// "$1.25" to "1.25", reporting whether there was one.
+func stripCurrencySymbol(amount string) (string, bool) {
+	r, size := utf8.DecodeRuneInString(amount)
+	if !unicode.Is(unicode.Sc, r) {
+		return amount, false
+	}
+
+	return amount[size:], true
+}
+
+// maxAmountDollars is the largest whole dollar value of an amount which may be
+// represented as cents without overflow.
+const maxAmountDollars = math.MaxInt/100 - 1
+
+// parseAmount parses a string representing a money value and converts it to an
+// integer representing the value as cents, e.g. "67.10" to 6710. Amounts must
+// have at most two decimal places, a single decimal place represents tens of
+// cents, e.g. "6.5" to 650. Leading and trailing whitespace is ignored, but
+// amounts with interior whitespace, e.g. "15 .30", are rejected.
+func parseAmount(amount string) (int, error) {
+	amount = strings.TrimSpace(amount)
+	if strings.ContainsFunc(amount, unicode.IsSpace) {
+		return 0, fmt.Errorf("%w %q, must not contain whitespace", ErrInvalidAmount, amount)
+	}
+
+	// Only plain decimal notation is accepted, rejecting inputs such as
+	// "1e3.00", "0x10.00", or "inf" that are otherwise parsed in surprising
+	// ways or fail with confusing errors.
+	if strings.ContainsFunc(amount, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' }) {
+		return 0, fmt.Errorf("%w %q, must be in decimal notation, e.g. \"15.30\"", ErrInvalidAmount, amount)
+	}
+
+	dollarsPart, centsPart, ok := strings.Cut(amount, ".")
+	if !ok {
+		return 0, fmt.Errorf("%w %q, missing decimal point", ErrInvalidAmount, amount)
+	}
+
+	if len(centsPart) == 0 || len(centsPart) > 2 {
+		return 0, fmt.Errorf("%w %q, must have one or two decimal places", ErrInvalidAmount, amount)
+	}
+	for _, r := range centsPart {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%w %q, invalid cents %q", ErrInvalidAmount, amount, centsPart)
+		}
+	}
+
+	dollars, err := strconv.Atoi(dollarsPart)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q, invalid dollars %q", ErrInvalidAmount, amount, dollarsPart)
+	}
+
+	// Reject dollars which would overflow once converted to cents.
+	if dollars > maxAmountDollars || dollars < -maxAmountDollars {
+		return 0, fmt.Errorf("%w %q, out of range", ErrInvalidAmount, amount)
+	}
+
+	cents, _ := strconv.Atoi(centsPart)
+	if len(centsPart) == 1 {
+		cents *= 10
+	}
+
+	if strings.HasPrefix(dollarsPart, "-") {
+		return dollars*100 - cents, nil
+	}
+
+	return dollars*100 + cents, nil
+}
+
+// formatDiscount formats the discount as accepted by [parseDiscount], or the
+// empty string if there is no discount.
+func formatDiscount(discount Discount) string {
+	switch {
+	case discount.Percent > 0:
+		return fmt.Sprintf("%d%%", discount.Percent)
+	case discount.Amount > 0:
+		return formatAmount(discount.Amount)
+	default:
+		return ""
+	}
+}
+
+// formatAmount formats an integer representing a money value as cents into a
+// string, e.g. 6710 to "67.10". It is the inverse of [parseAmount].
+func formatAmount(cents int) string {
+	return formatCurrencyAmount(cents, "")
+}
+
+// currencyMinorUnits are the number of decimal digits of the minor unit of
+// currencies, by ISO 4217 code, which differ from the two digits of most
+// currencies.
+var currencyMinorUnits = map[string]int{
+	"BHD": 3,
+	"CLP": 0,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"VND": 0,
+}
+
+// minorUnits returns the number of decimal digits of the minor unit of the
+// currency, defaulting to two digits for unknown currencies.
+func minorUnits(currency string) int {
+	if digits, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return digits
+	}
+
+	return 2
+}
+
+// formatCurrencyAmount formats an integer representing a money value as minor
+// units of the currency into a string with the precision of the minor unit,
+// e.g. 6710 USD to "67.10" and 6710 JPY to "6710". An empty currency is
+// formatted with two digits, as USD.
+func formatCurrencyAmount(amount int, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := minorUnits(currency)
+	if digits == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+
+	scale := 1
+	for range digits {
+		scale *= 10
 	}
 
-	// Truncate fractional cents if present.
-	return dollars*100 + cents%100, nil
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
 }
@@ -1,12 +1,28 @@
 package fetch
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestIntegration(tt *testing.T) {
@@ -93,3 +109,3141 @@ func TestIntegration(tt *testing.T) {
 		})
 	}
 }
+
+func TestIntegrationErrors(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		status  int
+		message string
+	}{
+		{
+			name:    "process receipt wrong method",
+			method:  "GET",
+			path:    "/receipts/process",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'POST'",
+		},
+		{
+			name:    "get points wrong method",
+			method:  "POST",
+			path:    "/receipts/abc/points",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be one of 'GET', 'PUT'",
+		},
+		{
+			name:    "receipt wrong method",
+			method:  "POST",
+			path:    "/receipts/abc",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be one of 'DELETE', 'GET', 'PUT'",
+		},
+		{
+			name:    "list receipts wrong method",
+			method:  "DELETE",
+			path:    "/receipts",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'GET'",
+		},
+		{
+			name:    "export receipts wrong method",
+			method:  "POST",
+			path:    "/receipts/export.csv",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'GET'",
+		},
+		{
+			name:    "import receipts wrong method",
+			method:  "GET",
+			path:    "/receipts/import",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'POST'",
+		},
+		{
+			name:    "simulate points wrong method",
+			method:  "GET",
+			path:    "/receipts/simulate",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'POST'",
+		},
+		{
+			name:    "batch points wrong method",
+			method:  "GET",
+			path:    "/receipts/points:batch",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'POST'",
+		},
+		{
+			name:    "version wrong method",
+			method:  "POST",
+			path:    "/version",
+			status:  http.StatusMethodNotAllowed,
+			message: "must be 'GET'",
+		},
+		{
+			name:    "malformed JSON",
+			method:  "POST",
+			path:    "/receipts/process",
+			body:    `{"retailer": `,
+			status:  http.StatusBadRequest,
+			message: "failed to parse process receipt request",
+		},
+		{
+			name:    "invalid total",
+			method:  "POST",
+			path:    "/receipts/process",
+			body:    `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "abc"}`,
+			status:  http.StatusBadRequest,
+			message: `invalid receipt total "abc"`,
+		},
+		{
+			name:    "invalid item price",
+			method:  "POST",
+			path:    "/receipts/process",
+			body:    `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [{"shortDescription": "Gum", "price": "1"}], "total": "1.00"}`,
+			status:  http.StatusBadRequest,
+			message: `invalid price of items[0] "Gum"`,
+		},
+		{
+			name:    "invalid purchase date",
+			method:  "POST",
+			path:    "/receipts/process",
+			body:    `{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01", "total": "1.00"}`,
+			status:  http.StatusBadRequest,
+			message: `failed to parse purchase date "2022-13-01"`,
+		},
+		{
+			name:    "invalid purchase time",
+			method:  "POST",
+			path:    "/receipts/process",
+			body:    `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "25:01", "total": "1.00"}`,
+			status:  http.StatusBadRequest,
+			message: "invalid hour value '25'",
+		},
+		{
+			name:    "unknown ID points",
+			method:  "GET",
+			path:    "/receipts/unknown/points",
+			status:  http.StatusNotFound,
+			message: `no receipt with ID "unknown" exists`,
+		},
+		{
+			name:    "unknown ID receipt",
+			method:  "GET",
+			path:    "/receipts/unknown",
+			status:  http.StatusNotFound,
+			message: `no receipt with ID "unknown" exists`,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("error status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			if ct := rw.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("error content type does not match, got %q, want %q", ct, "application/json")
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if !strings.Contains(got.Message, tc.message) {
+				t.Fatalf("error message does not match, got %q, want it to contain %q", got.Message, tc.message)
+			}
+		})
+	}
+}
+
+func TestExportReceipts(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/receipts/export.csv", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to export receipts, got %d status code, want 200", rw.Code)
+	}
+
+	if ct := rw.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("export content type does not match, got %q, want %q", ct, "text/csv")
+	}
+
+	records, err := csv.NewReader(rw.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse exported CSV, got %v, want no error", err)
+	}
+
+	want := [][]string{
+		{"id", "retailer", "purchaseDate", "purchaseTime", "total", "points"},
+		{id, "Target", "2022-01-01", "13:01", "35.35", "28"},
+	}
+
+	if got := fmt.Sprint(records); got != fmt.Sprint(want) {
+		t.Fatalf("exported CSV does not match, got %v, want %v", got, want)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for _, tc := range []struct {
+		amount string
+		want   int
+		err    bool
+	}{
+		{amount: "67.10", want: 6710},
+		{amount: "6.05", want: 605},
+		{amount: "6.5", want: 650},
+		{amount: "0.00", want: 0},
+		{amount: "-1.50", want: -150},
+		{amount: "2.500", err: true},
+		{amount: "2.", err: true},
+		{amount: "2", err: true},
+		{amount: "2.-5", err: true},
+		{amount: "abc.00", err: true},
+		{amount: " 15.30 ", want: 1530},
+		{amount: "\t15.30\n", want: 1530},
+		{amount: "15 .30", err: true},
+		{amount: "15. 30", err: true},
+		{amount: "- 15.30", err: true},
+		{amount: "1e3.00", err: true},
+		{amount: "inf", err: true},
+		{amount: "inf.00", err: true},
+		{amount: "NaN.00", err: true},
+		{amount: "0x10.00", err: true},
+		{amount: "+1.00", err: true},
+		{amount: "1-1.00", err: true},
+		{amount: "92233720368547758.07", err: true},
+		{amount: "-92233720368547758.08", err: true},
+		{amount: "9223372036854775807.00", err: true},
+	} {
+		got, err := parseAmount(tc.amount)
+		if (err != nil) != tc.err {
+			t.Errorf("parseAmount(%q) error does not match, got %v, want error %t", tc.amount, err, tc.err)
+			continue
+		}
+
+		if got != tc.want {
+			t.Errorf("parseAmount(%q) does not match, got %d, want %d", tc.amount, got, tc.want)
+		}
+	}
+}
+
+func TestInvalidItemPrice(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []ProcessReceiptItem{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "2.500"},
+		},
+		Total: "8.99",
+	})))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("process receipt status does not match, got %d, want 400", rw.Code)
+	}
+
+	var got Error
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse error response, got %v, want no error", err)
+	}
+
+	for _, want := range []string{"items[1]", "Emils Cheese Pizza", "2.500"} {
+		if !strings.Contains(got.Message, want) {
+			t.Fatalf("error message does not match, got %q, want it to contain %q", got.Message, want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for _, tc := range []struct {
+		cents int
+		want  string
+	}{
+		{cents: 6710, want: "67.10"},
+		{cents: 605, want: "6.05"},
+		{cents: 600, want: "6.00"},
+		{cents: 0, want: "0.00"},
+	} {
+		if got := formatAmount(tc.cents); got != tc.want {
+			t.Errorf("formatAmount(%d) does not match, got %q, want %q", tc.cents, got, tc.want)
+		}
+
+		if got, err := parseAmount(formatAmount(tc.cents)); err != nil || got != tc.cents {
+			t.Errorf("parseAmount(formatAmount(%d)) does not round trip, got %d (%v), want %d", tc.cents, got, err, tc.cents)
+		}
+	}
+}
+
+func TestFormatCurrencyAmount(t *testing.T) {
+	for _, tc := range []struct {
+		amount   int
+		currency string
+		want     string
+	}{
+		{amount: 6710, currency: "", want: "67.10"},
+		{amount: 6710, currency: "USD", want: "67.10"},
+		{amount: 605, currency: "usd", want: "6.05"},
+		{amount: -605, currency: "USD", want: "-6.05"},
+		{amount: 6710, currency: "JPY", want: "6710"},
+		{amount: -6710, currency: "JPY", want: "-6710"},
+		{amount: 6710, currency: "KWD", want: "6.710"},
+		{amount: 5, currency: "BHD", want: "0.005"},
+		{amount: 6710, currency: "XYZ", want: "67.10"},
+	} {
+		if got := formatCurrencyAmount(tc.amount, tc.currency); got != tc.want {
+			t.Errorf("formatCurrencyAmount(%d, %q) does not match, got %q, want %q", tc.amount, tc.currency, got, tc.want)
+		}
+	}
+}
+
+func TestMaxTotal(tt *testing.T) {
+	api := NewAPI(WithMaxTotal(1000))
+
+	for _, tc := range []struct {
+		name   string
+		total  string
+		status int
+	}{
+		{
+			name:   "under maximum",
+			total:  "9.99",
+			status: http.StatusOK,
+		},
+		{
+			name:   "at maximum",
+			total:  "10.00",
+			status: http.StatusOK,
+		},
+		{
+			name:   "over maximum",
+			total:  "10.01",
+			status: http.StatusUnprocessableEntity,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []ProcessReceiptItem{
+					{ShortDescription: "Pepsi - 12-oz", Price: tc.total},
+				},
+				Total: tc.total,
+			})))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestStrictTotals(tt *testing.T) {
+	// The item prices sum to 2.50.
+	items := []ProcessReceiptItem{
+		{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+		{ShortDescription: "Dasani", Price: "1.25"},
+	}
+
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		total  string
+		status int
+	}{
+		{name: "unchecked", total: "3.00", status: http.StatusOK},
+		{name: "exact", opts: []Option{WithStrictTotals(0)}, total: "2.50", status: http.StatusOK},
+		{name: "exact mismatch", opts: []Option{WithStrictTotals(0)}, total: "2.51", status: http.StatusUnprocessableEntity},
+		{name: "within tolerance over", opts: []Option{WithStrictTotals(1)}, total: "2.51", status: http.StatusOK},
+		{name: "within tolerance under", opts: []Option{WithStrictTotals(1)}, total: "2.49", status: http.StatusOK},
+		{name: "beyond tolerance", opts: []Option{WithStrictTotals(1)}, total: "2.52", status: http.StatusUnprocessableEntity},
+		{name: "large difference", opts: []Option{WithStrictTotals(1)}, total: "3.00", status: http.StatusUnprocessableEntity},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items:        items,
+				Total:        tc.total,
+			})))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestMaxDescriptionLength(tt *testing.T) {
+	api := NewAPI(WithMaxDescriptionLength(10))
+
+	for _, tc := range []struct {
+		name        string
+		description string
+		status      int
+	}{
+		{
+			name:        "at maximum",
+			description: "0123456789",
+			status:      http.StatusOK,
+		},
+		{
+			name:        "at maximum with whitespace",
+			description: "  0123456789  ",
+			status:      http.StatusOK,
+		},
+		{
+			name:        "over maximum",
+			description: "0123456789A",
+			status:      http.StatusBadRequest,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: "13:01",
+				Items: []ProcessReceiptItem{
+					{ShortDescription: "Pepsi", Price: "1.00"},
+					{ShortDescription: tc.description, Price: "1.00"},
+				},
+				Total: "2.00",
+			})))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			if tc.status == http.StatusBadRequest {
+				if want := "description of items[1]"; !strings.Contains(rw.Body.String(), want) {
+					t.Fatalf("error does not match, got %q, want it to contain %q", rw.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateReceipt(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	body, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := json.Unmarshal(body, &prreq); err != nil {
+		t.Fatalf("failed to parse receipt file, got %v, want no error", err)
+	}
+
+	// Walmart has one more alphanumeric character than Target.
+	prreq.Retailer = "Walmart"
+
+	{
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/receipts/%s", id), strings.NewReader(receiptJSON(t, &prreq)))
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to update receipt, got %d status code, want 200", rw.Code)
+		}
+
+		var got GetPointsResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse update response, got %v, want no error", err)
+		}
+
+		if points := got.Points; points != 29 {
+			t.Fatalf("updated receipt points do not match, got %d, want 29", points)
+		}
+	}
+
+	if points := getPoints(t, api, id); points != 29 {
+		t.Fatalf("stored receipt points do not match, got %d, want 29", points)
+	}
+
+	{
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/receipts/unknown", strings.NewReader(receiptJSON(t, &prreq)))
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("update of unknown receipt status does not match, got %d, want 404", rw.Code)
+		}
+	}
+}
+
+func TestGetReceiptETag(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	// get fetches the receipt with the If-None-Match header, returning the
+	// status code and ETag of the response.
+	get := func(ifNoneMatch string) (int, string) {
+		t.Helper()
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s", id), nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code == http.StatusNotModified && rw.Body.Len() > 0 {
+			t.Fatalf("not modified response has body, got %q, want none", rw.Body)
+		}
+
+		return rw.Code, rw.Header().Get("ETag")
+	}
+
+	status, etag := get("")
+	if status != http.StatusOK || etag == "" {
+		t.Fatalf("failed to get receipt, got %d status code with ETag %q, want 200 with ETag", status, etag)
+	}
+
+	if status, got := get(etag); status != http.StatusNotModified || got != etag {
+		t.Fatalf("conditional get does not match, got %d status code with ETag %q, want 304 with ETag %q", status, got, etag)
+	}
+	if status, _ := get(`W/` + etag + `, "other"`); status != http.StatusNotModified {
+		t.Fatalf("weak conditional get status does not match, got %d, want 304", status)
+	}
+	if status, _ := get(`"other"`); status != http.StatusOK {
+		t.Fatalf("mismatched conditional get status does not match, got %d, want 200", status)
+	}
+
+	body := receiptJSON(t, &ProcessReceiptRequest{
+		Retailer:     "Walmart",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Total:        "1.00",
+	})
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/receipts/%s", id), strings.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to update receipt, got %d status code, want 200", rw.Code)
+	}
+
+	status, updated := get(etag)
+	if status != http.StatusOK {
+		t.Fatalf("conditional get after update status does not match, got %d, want 200", status)
+	}
+	if updated == etag {
+		t.Fatalf("ETag after update does not match, got %q, want different ETag", updated)
+	}
+}
+
+func TestGetReceiptETagRepresentations(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	// Each representation of the receipt has the ETag of its own body.
+	etags := make(map[string]string)
+	for _, query := range []string{"", "?explain=true", "?fields=id,points"} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/receipts/"+id+query, nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get receipt%s, got %d status code, want 200", query, rw.Code)
+		}
+
+		etag := rw.Header().Get("ETag")
+		if want := api.receiptETag(rw.Body.Bytes()); etag != want {
+			t.Fatalf("ETag of receipt%s does not match body, got %q, want %q", query, etag, want)
+		}
+		if other, ok := etags[etag]; ok {
+			t.Fatalf("ETag of receipt%s matches receipt%s, got %q for both", query, other, etag)
+		}
+		etags[etag] = query
+	}
+}
+
+func TestWithHash(t *testing.T) {
+	receipt := &Receipt{
+		ID:        "7fb1377b-b223-49d9-a31a-5a02701dd310",
+		Retailer:  "Target",
+		Purchased: time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC),
+		Total:     100,
+	}
+	receipt.SetPoints(87, "initial calculation", time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	// etag returns the ETag of the receipt served by an API with the options.
+	etag := func(opts ...Option) string {
+		t.Helper()
+
+		api, err := NewAPIWithReceipts([]*Receipt{receipt}, opts...)
+		if err != nil {
+			t.Fatalf("failed to create API, got %v, want no error", err)
+		}
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/receipts/"+receipt.ID, nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get receipt, got %d status code, want 200", rw.Code)
+		}
+
+		return rw.Header().Get("ETag")
+	}
+
+	def := etag()
+	if got := etag(WithHash(sha256.New)); got != def {
+		t.Fatalf("SHA-256 ETag does not match default, got %q, want %q", got, def)
+	}
+	if got := etag(WithHash(sha512.New)); got == def {
+		t.Fatalf("SHA-512 ETag matches default, got %q for both", got)
+	}
+}
+
+func TestWithHashContentHash(t *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	// contentHash returns the content hash of the receipt processed by an
+	// API with the options, verifying that it deduplicates on that hash.
+	contentHash := func(opts ...Option) string {
+		t.Helper()
+
+		api := NewAPI(opts...)
+		id := processReceiptBody(t, api, string(body))
+
+		receipt, _ := api.Receipt(id)
+
+		canonical, err := CanonicalJSON(receipt)
+		if err != nil {
+			t.Fatalf("failed to encode canonical receipt, got %v, want no error", err)
+		}
+		if want := hex.EncodeToString(api.hash(canonical)); receipt.ContentHash != want {
+			t.Fatalf("content hash does not match, got %q, want %q", receipt.ContentHash, want)
+		}
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+		req.Header.Set("If-None-Match", "*")
+		req.Header.Set(ContentHashHeader, receipt.ContentHash)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusPreconditionFailed {
+			t.Fatalf("duplicate process receipt status does not match, got %d, want 412", rw.Code)
+		}
+
+		return receipt.ContentHash
+	}
+
+	def := contentHash()
+	if got := contentHash(WithHash(sha512.New)); got == def || len(got) != 2*sha512.Size {
+		t.Fatalf("SHA-512 content hash does not match, got %q, want a SHA-512 hash differing from %q", got, def)
+	}
+}
+
+func TestWithHashNil(t *testing.T) {
+	defer func() {
+		if v := recover(); v == nil {
+			t.Fatalf("WithHash(nil) did not panic, want panic")
+		}
+	}()
+
+	WithHash(nil)
+}
+
+func TestGetReceiptFields(tt *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(tt, api, "testdata/readme-target-receipt.json")
+
+	for _, tc := range []struct {
+		name   string
+		fields string
+		status int
+		want   map[string]any
+	}{
+		{
+			name:   "subset",
+			fields: "id,points",
+			status: http.StatusOK,
+			want:   map[string]any{"id": id, "points": 28.0},
+		},
+		{
+			name:   "omitted field",
+			fields: "retailer,discount",
+			status: http.StatusOK,
+			want:   map[string]any{"retailer": "Target"},
+		},
+		{
+			name:   "unknown field",
+			fields: "id,bogus",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "empty field",
+			fields: "id,",
+			status: http.StatusBadRequest,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s?fields=%s", id, tc.fields), nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("get receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var got map[string]any
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("selected fields do not match, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessReceiptErrorStatus(tt *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	api := NewAPI(WithClock(func() time.Time { return now }))
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{
+			name:   "malformed body",
+			body:   `{"retailer": "Target",`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unparseable purchase date",
+			body:   `{"retailer": "Target", "purchaseDate": "tomorrow", "purchaseTime": "13:01", "total": "1.00"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "future purchase date",
+			body:   `{"retailer": "Target", "purchaseDate": "2024-03-17", "purchaseTime": "13:01", "total": "1.00"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "negative total",
+			body:   `{"retailer": "Target", "purchaseDate": "2024-03-14", "purchaseTime": "13:01", "total": "-1.00"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "valid receipt",
+			body:   `{"retailer": "Target", "purchaseDate": "2024-03-15", "purchaseTime": "13:01", "total": "1.00"}`,
+			status: http.StatusOK,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(tc.body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestPurchaseDateTimePresence(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		name         string
+		purchaseDate string
+		purchaseTime string
+		status       int
+		message      string
+	}{
+		{
+			name:         "date only",
+			purchaseDate: "2022-01-01",
+			status:       http.StatusBadRequest,
+			message:      "missing purchaseTime, required with purchaseDate",
+		},
+		{
+			name:         "time only",
+			purchaseTime: "13:01",
+			status:       http.StatusBadRequest,
+			message:      "missing purchaseDate, required with purchaseTime",
+		},
+		{
+			name:    "neither",
+			status:  http.StatusBadRequest,
+			message: "missing purchaseDate and purchaseTime",
+		},
+		{
+			name:         "both",
+			purchaseDate: "2022-01-01",
+			purchaseTime: "13:01",
+			status:       http.StatusOK,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: tc.purchaseDate,
+				PurchaseTime: tc.purchaseTime,
+				Total:        "1.00",
+			})))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			if tc.message == "" {
+				return
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if !strings.Contains(got.Message, tc.message) {
+				t.Fatalf("error message does not match, got %q, want it to contain %q", got.Message, tc.message)
+			}
+		})
+	}
+}
+
+func TestPurchaseTimeErrorCodes(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		purchaseTime string
+		code         string
+		wantErr      error
+	}{
+		{purchaseTime: "25:00", code: "invalid_hour", wantErr: ErrInvalidHour},
+		{purchaseTime: "10:70", code: "invalid_minute", wantErr: ErrInvalidMinute},
+		{purchaseTime: "bad", code: "invalid_time_format", wantErr: ErrTimeFormat},
+	} {
+		tt.Run(tc.purchaseTime, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := parsePurchased(DefaultDateLayouts, "2022-01-01", tc.purchaseTime); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("parse purchased error does not match, got %v, want %v", err, tc.wantErr)
+			}
+
+			body := receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: "2022-01-01",
+				PurchaseTime: tc.purchaseTime,
+				Total:        "1.00",
+			})
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusBadRequest {
+				t.Fatalf("status code does not match, got %d, want 400", rw.Code)
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if got.Code != tc.code {
+				t.Fatalf("error code does not match, got %q, want %q", got.Code, tc.code)
+			}
+		})
+	}
+
+	tt.Run("minute value", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parsePurchased(DefaultDateLayouts, "2022-01-01", "10:70")
+		if err == nil || !strings.Contains(err.Error(), "'70'") {
+			t.Fatalf("minute error does not match, got %v, want it to report minute '70'", err)
+		}
+	})
+}
+
+func TestEmptyReceipts(tt *testing.T) {
+	// An empty receipt at Target with a round dollar total on an odd day
+	// earns 6 + 50 + 25 + 6 points from the rules independent of items.
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [], "total": "1.00"}`
+
+	for _, tc := range []struct {
+		name      string
+		treatment string
+		status    int
+		points    int
+	}{
+		{name: "default", treatment: "", status: http.StatusOK, points: 87},
+		{name: "score", treatment: EmptyReceiptsScore, status: http.StatusOK, points: 87},
+		{name: "zero", treatment: EmptyReceiptsZero, status: http.StatusOK, points: 0},
+		{name: "reject", treatment: EmptyReceiptsReject, status: http.StatusUnprocessableEntity},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rs := DefaultRuleset()
+			rs.EmptyReceipts = tc.treatment
+
+			api := NewAPI(WithRuleset(rs))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var resp ProcessReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to parse process receipt response, got %v, want no error", err)
+			}
+
+			if got := getPoints(t, api, resp.ID); got != tc.points {
+				t.Fatalf("points do not match, got %d, want %d", got, tc.points)
+			}
+		})
+	}
+}
+
+func TestEmptyItemsRepresentations(tt *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		items string
+	}{
+		{name: "empty", items: `"items": [], `},
+		{name: "null", items: `"items": null, `},
+		{name: "omitted", items: ``},
+	} {
+		body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", ` + tc.items + `"total": "1.00"}`
+
+		tt.Run(tc.name+"/score", func(t *testing.T) {
+			api := NewAPI()
+
+			id := processReceiptBody(t, api, body)
+
+			if got := getPoints(t, api, id); got != 87 {
+				t.Fatalf("points do not match, got %d, want 87", got)
+			}
+		})
+
+		tt.Run(tc.name+"/reject", func(t *testing.T) {
+			rs := DefaultRuleset()
+			rs.EmptyReceipts = EmptyReceiptsReject
+
+			api := NewAPI(WithRuleset(rs))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("process receipt status does not match, got %d, want 422", rw.Code)
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if want := "at least one item is required"; !strings.Contains(got.Message, want) {
+				t.Fatalf("error message does not match, got %q, want it to contain %q", got.Message, want)
+			}
+		})
+	}
+}
+
+func TestZeroPriceItems(tt *testing.T) {
+	// A receipt at Target with a quarter total on an odd day earns 6 + 25 + 6
+	// points independent of the items, plus 5 points if both items are
+	// counted as a pair.
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [{"shortDescription": "Gatorade", "price": "2.25"}, {"shortDescription": "Pepsi", "price": "0.00"}], "total": "2.25"}`
+
+	for _, tc := range []struct {
+		name      string
+		treatment string
+		status    int
+		points    int
+	}{
+		{name: "count", treatment: ZeroPriceItemsCount, status: http.StatusOK, points: 42},
+		{name: "exclude", treatment: ZeroPriceItemsExclude, status: http.StatusOK, points: 37},
+		{name: "reject", treatment: ZeroPriceItemsReject, status: http.StatusUnprocessableEntity},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rs := DefaultRuleset()
+			rs.ZeroPriceItems = tc.treatment
+
+			api := NewAPI(WithRuleset(rs))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var resp ProcessReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to parse process receipt response, got %v, want no error", err)
+			}
+
+			if got := getPoints(t, api, resp.ID); got != tc.points {
+				t.Fatalf("points do not match, got %d, want %d", got, tc.points)
+			}
+		})
+	}
+}
+
+func TestNewCustomerBonus(tt *testing.T) {
+	rs := DefaultRuleset()
+	rs.NewCustomerBonus = 100
+
+	for _, tc := range []struct {
+		name    string
+		ruleset *Ruleset
+		userIDs []string
+		want    []int
+	}{
+		{
+			name:    "first and second receipt",
+			ruleset: rs,
+			userIDs: []string{"alice", "alice"},
+			want:    []int{128, 28},
+		},
+		{
+			name:    "different users",
+			ruleset: rs,
+			userIDs: []string{"alice", "bob"},
+			want:    []int{128, 128},
+		},
+		{
+			name:    "anonymous receipts",
+			ruleset: rs,
+			userIDs: []string{"", ""},
+			want:    []int{28, 28},
+		},
+		{
+			name:    "bonus disabled",
+			ruleset: DefaultRuleset(),
+			userIDs: []string{"alice", "alice"},
+			want:    []int{28, 28},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(WithRuleset(tc.ruleset))
+
+			body, err := os.ReadFile("testdata/readme-target-receipt.json")
+			if err != nil {
+				t.Fatalf("failed to read receipt, got %v, want no error", err)
+			}
+
+			var prreq ProcessReceiptRequest
+			if err := json.Unmarshal(body, &prreq); err != nil {
+				t.Fatalf("failed to parse receipt, got %v, want no error", err)
+			}
+
+			for i, userID := range tc.userIDs {
+				prreq.UserID = userID
+				id := processReceiptBody(t, api, receiptJSON(t, &prreq))
+
+				if got := getPoints(t, api, id); got != tc.want[i] {
+					t.Fatalf("points of receipt %d do not match, got %d, want %d", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewCustomerBonusSharedStore(t *testing.T) {
+	rs := DefaultRuleset()
+	rs.NewCustomerBonus = 100
+
+	// The prior receipts of a user are found in the store, e.g. after a
+	// restart or on another API instance sharing the store.
+	store := NewMemoryStore()
+
+	body, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := json.Unmarshal(body, &prreq); err != nil {
+		t.Fatalf("failed to parse receipt, got %v, want no error", err)
+	}
+	prreq.UserID = "alice"
+
+	for i, want := range []int{128, 28} {
+		api := NewAPI(WithRuleset(rs), WithStore(store))
+
+		id := processReceiptBody(t, api, receiptJSON(t, &prreq))
+
+		if got := getPoints(t, api, id); got != want {
+			t.Fatalf("points of receipt %d do not match, got %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestReceiptDiscount(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		name     string
+		discount string
+		status   int
+		points   int
+	}{
+		{name: "none", status: http.StatusOK, points: 75},
+		{name: "percent", discount: "10%", status: http.StatusOK, points: 75},
+		{name: "fixed", discount: "1.50", status: http.StatusOK, points: 25},
+		{name: "invalid percent", discount: "ten%", status: http.StatusBadRequest},
+		{name: "fractional percent", discount: "12.5%", status: http.StatusBadRequest},
+		{name: "invalid amount", discount: "1.5.0", status: http.StatusBadRequest},
+		{name: "negative percent", discount: "-10%", status: http.StatusUnprocessableEntity},
+		{name: "over percent", discount: "101%", status: http.StatusUnprocessableEntity},
+		{name: "over total", discount: "20.01", status: http.StatusUnprocessableEntity},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &ProcessReceiptRequest{
+				Retailer:     "&",
+				PurchaseDate: "2022-01-02",
+				PurchaseTime: "08:00",
+				Total:        "20.00",
+				Discount:     tc.discount,
+			})))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d (%s)", rw.Code, tc.status, rw.Body.String())
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var resp ProcessReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to parse process receipt response, got %v, want no error", err)
+			}
+
+			if got := getPoints(t, api, resp.ID); got != tc.points {
+				t.Fatalf("points do not match, got %d, want %d", got, tc.points)
+			}
+		})
+	}
+}
+
+func TestDateLayouts(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		layouts []string
+		date    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "default layout",
+			date: "2024-03-15",
+			want: "2024-03-15",
+		},
+		{
+			name:    "default layout rejects US date",
+			date:    "03/15/2024",
+			wantErr: "failed to parse purchase date",
+		},
+		{
+			name:    "US layout",
+			layouts: []string{"2006-01-02", "01/02/2006"},
+			date:    "03/15/2024",
+			want:    "2024-03-15",
+		},
+		{
+			name:    "US layout keeps ISO dates",
+			layouts: []string{"2006-01-02", "01/02/2006"},
+			date:    "2024-03-15",
+			want:    "2024-03-15",
+		},
+		{
+			name:    "slash layout",
+			layouts: []string{"2006/01/02"},
+			date:    "2024/03/15",
+			want:    "2024-03-15",
+		},
+		{
+			name:    "unambiguous day and month",
+			layouts: []string{"01/02/2006", "02/01/2006"},
+			date:    "03/15/2024",
+			want:    "2024-03-15",
+		},
+		{
+			name:    "ambiguous day and month",
+			layouts: []string{"01/02/2006", "02/01/2006"},
+			date:    "03/04/2024",
+			wantErr: "ambiguous purchase date",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []Option
+			if tc.layouts != nil {
+				opts = append(opts, WithDateLayouts(tc.layouts...))
+			}
+			api := NewAPI(opts...)
+
+			receipt, _, err := api.parseReceipt(&ProcessReceiptRequest{
+				Retailer:     "Target",
+				PurchaseDate: tc.date,
+				PurchaseTime: "13:01",
+				Total:        "1.00",
+			})
+
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("parse receipt error does not match, got %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse receipt, got %v, want no error", err)
+			}
+
+			if got := receipt.Purchased.Format("2006-01-02"); got != tc.want {
+				t.Fatalf("purchase date does not match, got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDateLayoutsCopied(t *testing.T) {
+	layouts := []string{"01/02/2006"}
+	api := NewAPI(WithDateLayouts(layouts...))
+	layouts[0] = "2006-01-02"
+
+	if _, _, err := api.parseReceipt(&ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "03/15/2024",
+		PurchaseTime: "13:01",
+		Total:        "1.00",
+	}); err != nil {
+		t.Fatalf("failed to parse receipt after changing layouts, got %v, want no error", err)
+	}
+
+	if api := NewAPI(); &api.dateLayouts[0] == &DefaultDateLayouts[0] {
+		t.Fatalf("date layouts share DefaultDateLayouts, want a copy")
+	}
+}
+
+func TestDateLayoutsEmpty(t *testing.T) {
+	defer func() {
+		if v := recover(); v == nil {
+			t.Fatalf("WithDateLayouts without layouts did not panic, want panic")
+		}
+	}()
+
+	WithDateLayouts()
+}
+
+func TestDefaultPurchaseTime(tt *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		purchaseTime string
+		points       int
+	}{
+		{
+			name:         "noon",
+			purchaseTime: "12:00",
+			points:       6,
+		},
+		{
+			name:         "afternoon",
+			purchaseTime: "15:00",
+			points:       16,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(WithDefaultPurchaseTime(tc.purchaseTime))
+
+			// Only the odd day and, depending on the default purchase time,
+			// the afternoon purchase rules award points.
+			id := processReceiptBody(t, api, receiptJSON(t, &ProcessReceiptRequest{
+				PurchaseDate: "2022-01-01",
+				Total:        "1.01",
+			}))
+
+			if points := getPoints(t, api, id); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+
+	for _, purchaseTime := range []string{"", "noon", "24:00", "12:60"} {
+		tt.Run("invalid "+purchaseTime, func(t *testing.T) {
+			defer func() {
+				if v := recover(); v == nil {
+					t.Fatalf("WithDefaultPurchaseTime(%q) did not panic, want panic", purchaseTime)
+				}
+			}()
+
+			WithDefaultPurchaseTime(purchaseTime)
+		})
+	}
+}
+
+func TestReceiptMetadata(t *testing.T) {
+	api := NewAPI()
+
+	body, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := json.Unmarshal(body, &prreq); err != nil {
+		t.Fatalf("failed to parse receipt file, got %v, want no error", err)
+	}
+
+	prreq.Metadata = map[string]string{
+		"storeNumber": "1234",
+		"cashierId":   "c-42",
+	}
+
+	id := processReceiptBody(t, api, receiptJSON(t, &prreq))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get receipt, got %d status code, want 200", rw.Code)
+	}
+
+	var got ReceiptResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+	}
+
+	if fmt.Sprint(got.Metadata) != fmt.Sprint(prreq.Metadata) {
+		t.Fatalf("receipt metadata does not match, got %v, want %v", got.Metadata, prreq.Metadata)
+	}
+
+	// The readme target receipt is worth 28 points without metadata.
+	if got.Points != 28 {
+		t.Fatalf("receipt points do not match, got %d, want 28", got.Points)
+	}
+
+	prreq.Metadata = map[string]string{
+		"note": strings.Repeat("x", maxMetadataValueLength+1),
+	}
+
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receiptJSON(t, &prreq)))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("oversized metadata status does not match, got %d, want 422", rw.Code)
+	}
+}
+
+func TestAlwaysRecalculate(tt *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		points int
+	}{
+		{
+			name:   "protected",
+			points: 28,
+		},
+		{
+			name:   "always recalculate",
+			opts:   []Option{WithAlwaysRecalculate()},
+			points: 38,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rs := DefaultRuleset()
+			api := NewAPI(append(tc.opts, WithRuleset(rs))...)
+
+			id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+			// Tweak the rules after the receipt has been processed, doubling
+			// the points for the two item pairs on the receipt.
+			rs.ItemPairPoints = 10
+
+			if points := getPoints(t, api, id); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestReceipt(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	receipt, ok := api.Receipt(id)
+	if !ok {
+		t.Fatalf("failed to get receipt %q, want receipt", id)
+	}
+	if got, want := receipt.Points, 28; got != want {
+		t.Fatalf("receipt points do not match, got %d, want %d", got, want)
+	}
+
+	receipt.Points = 1000
+	receipt.Retailer = "Walmart"
+	receipt.PointsHistory[0].Reason = "tampered"
+
+	again, _ := api.Receipt(id)
+	if again.Points != 28 || again.Retailer != "Target" || again.PointsHistory[0].Reason != "initial calculation" {
+		t.Fatalf("stored receipt does not match, got %+v, want it unaffected by changes to the copy", again)
+	}
+
+	if _, ok := api.Receipt("unknown"); ok {
+		t.Fatalf("unknown receipt exists, want no receipt")
+	}
+}
+
+func TestReceiptItemsCopied(t *testing.T) {
+	// Recalculate points on every fetch so that any change to the stored items
+	// is reflected in the points.
+	api := NewAPI(WithAlwaysRecalculate())
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	receipt, _ := api.Receipt(id)
+	for i := range receipt.Items {
+		receipt.Items[i].Description = "abc"
+		receipt.Items[i].Price = 100000
+	}
+	receipt.Items = append(receipt.Items, ReceiptItem{Description: "xyz", Price: 100})
+
+	if got, want := getPoints(t, api, id), 28; got != want {
+		t.Fatalf("points do not match, got %d, want %d", got, want)
+	}
+}
+
+func TestNewAPIWithReceipts(tt *testing.T) {
+	receipts := func() []*Receipt {
+		a := &Receipt{ID: "7fb1377b-b223-49d9-a31a-5a02701dd310", Retailer: "Target", Total: 100}
+		a.SetPoints(28, "initial calculation", time.Now())
+
+		b := &Receipt{ID: "d4b6e5f2-3c1a-4b8e-9f0d-2a7c6e1b3d5f", Retailer: "Walgreens", Total: 265}
+		b.SetPoints(109, "initial calculation", time.Now())
+
+		return []*Receipt{a, b}
+	}
+
+	tt.Run("preloaded", func(t *testing.T) {
+		t.Parallel()
+
+		api, err := NewAPIWithReceipts(receipts())
+		if err != nil {
+			t.Fatalf("failed to create API, got %v, want no error", err)
+		}
+
+		for _, receipt := range receipts() {
+			if points := getPoints(t, api, receipt.ID); points != receipt.Points {
+				t.Fatalf("receipt %q points do not match, got %d, want %d", receipt.ID, points, receipt.Points)
+			}
+		}
+	})
+
+	tt.Run("given points", func(t *testing.T) {
+		t.Parallel()
+
+		receipt := &Receipt{ID: "7fb1377b-b223-49d9-a31a-5a02701dd310", Retailer: "Target", Total: 100, Points: 28}
+
+		api, err := NewAPIWithReceipts([]*Receipt{receipt})
+		if err != nil {
+			t.Fatalf("failed to create API, got %v, want no error", err)
+		}
+
+		if receipt.Scored {
+			t.Fatalf("preloaded receipt was modified, want the given receipt unchanged")
+		}
+
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("GET", "/receipts/"+receipt.ID+"/points", nil))
+
+		var got GetPointsResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse points response, got %v, want no error", err)
+		}
+
+		if got.Points != 28 || !got.Scored {
+			t.Fatalf("points response does not match, got %+v, want 28 scored points", got)
+		}
+	})
+
+	tt.Run("duplicate IDs", func(t *testing.T) {
+		t.Parallel()
+
+		duplicates := receipts()
+		duplicates[1].ID = duplicates[0].ID
+
+		if _, err := NewAPIWithReceipts(duplicates); err == nil {
+			t.Fatalf("create API error does not match, got nil, want error for duplicate IDs")
+		}
+	})
+
+	tt.Run("nil receipt", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := NewAPIWithReceipts(append(receipts(), nil)); err == nil {
+			t.Fatalf("create API error does not match, got nil, want error for nil receipt")
+		}
+	})
+
+	tt.Run("invalid ID", func(t *testing.T) {
+		t.Parallel()
+
+		invalid := receipts()
+		invalid[1].ID = "not-a-uuid"
+
+		if _, err := NewAPIWithReceipts(invalid); err == nil || !strings.Contains(err.Error(), "not-a-uuid") {
+			t.Fatalf("create API error does not match, got %v, want error for invalid ID", err)
+		}
+	})
+}
+
+func TestIDCollision(t *testing.T) {
+	// The generator collides once, returning the first ID again, then
+	// succeeds.
+	ids := []string{
+		"7fb1377b-b223-49d9-a31a-5a02701dd310",
+		"7fb1377b-b223-49d9-a31a-5a02701dd310",
+		"bd1c2a0e-2a9f-4f3e-9c1c-0b8a3f5e2d41",
+	}
+
+	var mu sync.Mutex
+	api := NewAPI(WithIDGenerator(func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := ids[0]
+		ids = ids[1:]
+
+		return id, nil
+	}))
+
+	first := processReceipt(t, api, "testdata/readme-target-receipt.json")
+	second := processReceipt(t, api, "testdata/simple-receipt.json")
+
+	if first == second {
+		t.Fatalf("receipt IDs collide, got %q for both receipts", first)
+	}
+	if got, want := second, "bd1c2a0e-2a9f-4f3e-9c1c-0b8a3f5e2d41"; got != want {
+		t.Fatalf("regenerated receipt ID does not match, got %q, want %q", got, want)
+	}
+
+	// The first receipt must not have been overwritten by the collision.
+	if got, want := getPoints(t, api, first), 28; got != want {
+		t.Fatalf("points of first receipt do not match, got %d, want %d", got, want)
+	}
+	if got, want := getPoints(t, api, second), 31; got != want {
+		t.Fatalf("points of second receipt do not match, got %d, want %d", got, want)
+	}
+}
+
+func TestIDPattern(tt *testing.T) {
+	api := NewAPI(WithIDPattern(regexp.MustCompile(`^acme-[0-9]{4}$`)))
+
+	// Store receipts directly since processed receipts are assigned UUIDs.
+	for _, id := range []string{"acme-0001", "7fb1377b-b223-49d9-a31a-5a02701dd310"} {
+		receipt := &Receipt{ID: id, Retailer: "Target", Total: 100}
+		receipt.SetPoints(10, "initial calculation", time.Now())
+
+		if err := api.store.Save(context.Background(), receipt); err != nil {
+			tt.Fatalf("failed to save receipt, got %v, want no error", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{
+			name:   "matching ID points",
+			method: "GET",
+			path:   "/receipts/acme-0001/points",
+			status: http.StatusOK,
+		},
+		{
+			name:   "matching ID receipt",
+			method: "GET",
+			path:   "/receipts/acme-0001",
+			status: http.StatusOK,
+		},
+		{
+			name:   "matching unknown ID",
+			method: "GET",
+			path:   "/receipts/acme-0002/points",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "UUID ID points",
+			method: "GET",
+			path:   "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "UUID ID delete",
+			method: "DELETE",
+			path:   "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "prefixed ID suffix",
+			method: "GET",
+			path:   "/receipts/acme-0001x",
+			status: http.StatusNotFound,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if got, want := rw.Code, tc.status; got != want {
+				t.Fatalf("status code does not match, got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestGetPointsWithoutMux(tt *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(tt, api, "testdata/simple-receipt.json")
+	want := getPoints(tt, api, id)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{
+			name:   "points path",
+			path:   "/receipts/" + id + "/points",
+			status: http.StatusOK,
+		},
+		{
+			name:   "prefixed points path",
+			path:   "/api/v1/receipts/" + id + "/points",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown ID",
+			path:   "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "missing ID",
+			path:   "/points",
+			status: http.StatusBadRequest,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tc.path, nil)
+
+			// Call the handler directly, bypassing the mux, so that the `id`
+			// path parameter is not set.
+			api.GetPoints(rw, req)
+
+			if got, want := rw.Code, tc.status; got != want {
+				t.Fatalf("status code does not match, got %d, want %d", got, want)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var got GetPointsResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse points response, got %v, want no error", err)
+			}
+
+			if got.Points != want {
+				t.Fatalf("points do not match, got %d, want %d", got.Points, want)
+			}
+		})
+	}
+}
+
+func TestZeroPointsScored(t *testing.T) {
+	api := NewAPI()
+
+	// No alphanumeric characters in the retailer name, no items, a total that
+	// is not a multiple of 0.25, and an even day morning purchase earn no
+	// points.
+	id := processReceiptBody(t, api, receiptJSON(t, &ProcessReceiptRequest{
+		Retailer:     "&",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "08:13",
+		Total:        "1.01",
+	}))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if got, want := rw.Body.String(), "{\"points\":0,\"scored\":true}\n"; got != want {
+		t.Fatalf("points response does not match, got %q, want %q", got, want)
+	}
+}
+
+func TestPointsHistory(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	api := NewAPI(WithClock(func() time.Time { return now }))
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	{
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", fmt.Sprintf("/receipts/%s/points", id), strings.NewReader(`{"points": 40, "reason": "customer satisfaction"}`))
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to adjust points, got %d status code, want 200", rw.Code)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points?history=true", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get points, got %d status code, want 200", rw.Code)
+	}
+
+	var got GetPointsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse points response, got %v, want no error", err)
+	}
+
+	want := GetPointsResponse{
+		Points: 40,
+		Scored: true,
+		History: []PointsEventResponse{
+			{Time: "2024-03-15T12:00:00Z", OldPoints: 0, NewPoints: 28, Reason: "initial calculation"},
+			{Time: "2024-03-15T12:00:00Z", OldPoints: 28, NewPoints: 40, Reason: "customer satisfaction"},
+		},
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("points history does not match, got %+v, want %+v", got, want)
+	}
+}
+
+func TestMaxPointsPerReceipt(tt *testing.T) {
+	rs := DefaultRuleset()
+	rs.MaxPointsPerReceipt = 100
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		points     int
+		wantReason string
+	}{
+		{
+			name:       "under cap",
+			path:       "testdata/readme-target-receipt.json",
+			points:     28,
+			wantReason: "initial calculation",
+		},
+		{
+			name:       "over cap",
+			path:       "testdata/readme-corner-market-receipt.json",
+			points:     100,
+			wantReason: "initial calculation, capped at 100 points",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(WithRuleset(rs))
+
+			id := processReceipt(t, api, tc.path)
+
+			if points := getPoints(t, api, id); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+
+			receipt, _ := api.Receipt(id)
+			if got := receipt.PointsHistory[0].Reason; got != tc.wantReason {
+				t.Fatalf("points history reason does not match, got %q, want %q", got, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestMaxPointsPerReceiptNewCustomerBonus(t *testing.T) {
+	rs := DefaultRuleset()
+	rs.MaxPointsPerReceipt = 100
+	rs.NewCustomerBonus = 100
+
+	api := NewAPI(WithRuleset(rs))
+
+	body := receiptJSON(t, &ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:13",
+		Total:        "1.25",
+		Items:        []ProcessReceiptItem{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		UserID:       "user-1",
+	})
+
+	id := processReceiptBody(t, api, body)
+
+	// The 31 points of the rules plus the 100 point bonus are capped.
+	if points := getPoints(t, api, id); points != 100 {
+		t.Fatalf("receipt points do not match, got %d, want 100", points)
+	}
+
+	receipt, _ := api.Receipt(id)
+
+	var reasons []string
+	for _, event := range receipt.PointsHistory {
+		reasons = append(reasons, event.Reason)
+	}
+
+	want := []string{"initial calculation", "new customer bonus, capped at 100 points"}
+	if !slices.Equal(reasons, want) {
+		t.Fatalf("points history reasons do not match, got %q, want %q", reasons, want)
+	}
+	if last := receipt.PointsHistory[len(receipt.PointsHistory)-1]; last.OldPoints != 31 || last.NewPoints != 100 {
+		t.Fatalf("new customer bonus event does not match, got %+v, want 31 to 100 points", last)
+	}
+}
+
+func TestNewCustomerBonusBreakdown(t *testing.T) {
+	rs := DefaultRuleset()
+	rs.NewCustomerBonus = 100
+
+	body := receiptJSON(t, &ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:13",
+		Total:        "1.25",
+		Items:        []ProcessReceiptItem{{ShortDescription: "Pepsi - 12-oz", Price: "1.25"}},
+		UserID:       "user-1",
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		api := NewAPI(WithRuleset(rs))
+
+		id := processReceiptBody(t, api, body)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s?explain=true", id), nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get receipt, got %d status code, want 200", rw.Code)
+		}
+
+		var got ReceiptResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+		}
+
+		if got.Explanation == nil {
+			t.Fatalf("receipt explanation is missing, want explanation")
+		}
+		if !slices.Contains(got.Explanation.Rules, RulePoints{Rule: "newCustomerBonus", Points: 100}) {
+			t.Fatalf("explanation rules do not match, got %+v, want newCustomerBonus rule", got.Explanation.Rules)
+		}
+		if got.Points != 131 || got.Explanation.RuleTotal != 131 || got.Explanation.Adjusted {
+			t.Fatalf("explanation does not match, got %d points and %+v, want 131 points unadjusted", got.Points, got.Explanation)
+		}
+	})
+
+	t.Run("always recalculate", func(t *testing.T) {
+		api := NewAPI(WithRuleset(rs), WithAlwaysRecalculate())
+
+		id := processReceiptBody(t, api, body)
+
+		if points := getPoints(t, api, id); points != 131 {
+			t.Fatalf("receipt points do not match, got %d, want 131", points)
+		}
+	})
+}
+
+func TestExplainReceipt(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	explain := func(t *testing.T) *ReceiptResponse {
+		t.Helper()
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s?explain=true", id), nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get receipt, got %d status code, want 200", rw.Code)
+		}
+
+		var got ReceiptResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+		}
+
+		if got.Explanation == nil {
+			t.Fatalf("receipt explanation is missing, want explanation")
+		}
+
+		return &got
+	}
+
+	wantRules := []RulePoints{
+		{Rule: "retailerName", Points: 6},
+		{Rule: "roundDollarTotal", Points: 0},
+		{Rule: "quarterTotal", Points: 0},
+		{Rule: "itemPairs", Points: 10},
+		{Rule: "leftoverItem", Points: 0},
+		{Rule: "itemDescription", Points: 6},
+		{Rule: "oddPurchaseDay", Points: 6},
+		{Rule: "afternoonPurchase", Points: 0},
+	}
+
+	got := explain(t)
+	if fmt.Sprint(got.Explanation.Rules) != fmt.Sprint(wantRules) {
+		t.Fatalf("explanation rules do not match, got %+v, want %+v", got.Explanation.Rules, wantRules)
+	}
+	if got.Points != 28 || got.Explanation.RuleTotal != 28 || got.Explanation.Adjusted {
+		t.Fatalf("explanation does not match, got %d points and %+v, want 28 points unadjusted", got.Points, got.Explanation)
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/receipts/%s/points", id), strings.NewReader(`{"points": 40, "reason": "customer satisfaction"}`))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to adjust points, got %d status code, want 200", rw.Code)
+	}
+
+	got = explain(t)
+	if got.Points != 40 || got.Explanation.RuleTotal != 28 || !got.Explanation.Adjusted {
+		t.Fatalf("explanation does not match, got %d points and %+v, want 40 points adjusted from 28", got.Points, got.Explanation)
+	}
+}
+
+func TestPersistBreakdowns(tt *testing.T) {
+	explain := func(t *testing.T, api *API, id string) *PointsExplanation {
+		t.Helper()
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s?explain=true", id), nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get receipt, got %d status code, want 200", rw.Code)
+		}
+
+		var got ReceiptResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+		}
+
+		if got.Explanation == nil {
+			t.Fatalf("receipt explanation is missing, want explanation")
+		}
+
+		return got.Explanation
+	}
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantTotal     int
+		wantItemPairs int
+	}{
+		{
+			name:          "persisted",
+			opts:          []Option{WithPersistBreakdowns()},
+			wantTotal:     28,
+			wantItemPairs: 10,
+		},
+		{
+			name:          "not persisted",
+			wantTotal:     38,
+			wantItemPairs: 20,
+		},
+		{
+			name:          "always recalculate",
+			opts:          []Option{WithPersistBreakdowns(), WithAlwaysRecalculate()},
+			wantTotal:     38,
+			wantItemPairs: 20,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		tt.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(test.opts...)
+
+			id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+			ruleset := DefaultRuleset()
+			ruleset.ItemPairPoints = 10
+			api.ruleset = ruleset
+
+			got := explain(t, api, id)
+
+			if got.RuleTotal != test.wantTotal {
+				t.Fatalf("explanation rule total does not match, got %d, want %d", got.RuleTotal, test.wantTotal)
+			}
+
+			var itemPairs int
+			for _, rule := range got.Rules {
+				if rule.Rule == "itemPairs" {
+					itemPairs = rule.Points
+				}
+			}
+			if itemPairs != test.wantItemPairs {
+				t.Fatalf("itemPairs points do not match, got %d, want %d", itemPairs, test.wantItemPairs)
+			}
+		})
+	}
+}
+
+func TestStripCurrencySymbols(tt *testing.T) {
+	body := receiptJSON(tt, &ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "08:13",
+		Items: []ProcessReceiptItem{
+			{ShortDescription: "Pepsi - 12-oz", Price: "\u20ac1.25"},
+		},
+		Total: "$1.25",
+	})
+
+	for _, tc := range []struct {
+		name         string
+		opts         []Option
+		wantStatus   int
+		wantWarnings []string
+	}{
+		{
+			name:       "default",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "strip",
+			opts:       []Option{WithStripCurrencySymbols()},
+			wantStatus: http.StatusOK,
+			wantWarnings: []string{
+				"stripped currency symbol from price of items[0] \"\u20ac1.25\"",
+				`stripped currency symbol from total "$1.25"`,
+			},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.wantStatus {
+				t.Fatalf("status code does not match, got %d, want %d", rw.Code, tc.wantStatus)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got ProcessReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+			}
+
+			if !slices.Equal(got.Warnings, tc.wantWarnings) {
+				t.Fatalf("warnings do not match, got %q, want %q", got.Warnings, tc.wantWarnings)
+			}
+
+			// 6 points for the retailer name and 25 for the quarter total.
+			if points := getPoints(t, api, got.ID); points != 31 {
+				t.Fatalf("receipt points do not match, got %d, want 31", points)
+			}
+		})
+	}
+
+	tt.Run("no warnings", func(t *testing.T) {
+		t.Parallel()
+
+		api := NewAPI(WithStripCurrencySymbols())
+
+		b, err := os.ReadFile("testdata/simple-receipt.json")
+		if err != nil {
+			t.Fatalf("failed to read receipt file, got %v, want no error", err)
+		}
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(b))
+
+		api.ServeHTTP(rw, req)
+
+		if strings.Contains(rw.Body.String(), "warnings") {
+			t.Fatalf("response includes warnings, got %s, want none", rw.Body)
+		}
+	})
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(string(body)))
+
+				api.ServeHTTP(rw, req)
+
+				if rw.Code != http.StatusOK {
+					t.Errorf("failed to process receipt, got %d status code, want 200", rw.Code)
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", id), nil)
+
+				api.ServeHTTP(rw, req)
+
+				var got GetPointsResponse
+				if err := json.NewDecoder(rw.Body).Decode(&got); err != nil || got.Points != 28 {
+					t.Errorf("receipt points do not match, got %d (%v), want 28", got.Points, err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := countReceipts(t, api); n != 1+8*50 {
+		t.Fatalf("stored receipt count does not match, got %d, want %d", n, 1+8*50)
+	}
+}
+
+func TestPrettyJSON(tt *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(tt, api, "testdata/simple-receipt.json")
+
+	for _, tc := range []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "compact",
+			path: fmt.Sprintf("/receipts/%s/points", id),
+			want: "{\"points\":31,\"scored\":true}\n",
+		},
+		{
+			name: "pretty",
+			path: fmt.Sprintf("/receipts/%s/points?pretty=true", id),
+			want: "{\n  \"points\": 31,\n  \"scored\": true\n}\n",
+		},
+		{
+			name: "pretty error",
+			path: "/receipts/unknown/points?pretty=true",
+			want: "{\n  \"error\": \"no receipt with ID \\\"unknown\\\" exists\",\n  \"code\": \"receipt_not_found\"\n}\n",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", tc.path, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if got := rw.Body.String(); got != tc.want {
+				t.Fatalf("response body does not match, got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrefersPlainText(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                      false,
+		"application/json":                      false,
+		"text/plain":                            true,
+		"TEXT/PLAIN; charset=utf-8":             true,
+		"*/*":                                   false,
+		"text/*":                                true,
+		"text/plain, application/json":          false,
+		"text/plain, application/json;q=0.5":    true,
+		"text/plain;q=0.1, */*":                 false,
+		"text/html, application/xml;q=0.9":      false,
+		"text/plain;q=0.9, application/*;q=0.8": true,
+	} {
+		if got := prefersPlainText(accept); got != want {
+			t.Fatalf("prefers plain text of %q does not match, got %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestErrorAccept(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "default",
+			contentType: "application/json",
+			body:        "{\"error\":\"no receipt with ID \\\"unknown\\\" exists\",\"code\":\"receipt_not_found\"}\n",
+		},
+		{
+			name:        "json",
+			accept:      "application/json",
+			contentType: "application/json",
+			body:        "{\"error\":\"no receipt with ID \\\"unknown\\\" exists\",\"code\":\"receipt_not_found\"}\n",
+		},
+		{
+			name:        "plain text",
+			accept:      "text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body:        "no receipt with ID \"unknown\" exists\n",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/receipts/unknown/points", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusNotFound {
+				t.Fatalf("status code does not match, got %d, want 404", rw.Code)
+			}
+			if got := rw.Header().Get("Content-Type"); got != tc.contentType {
+				t.Fatalf("content type does not match, got %q, want %q", got, tc.contentType)
+			}
+			if got := rw.Body.String(); got != tc.body {
+				t.Fatalf("error body does not match, got %q, want %q", got, tc.body)
+			}
+		})
+	}
+}
+
+func TestErrorCodes(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{
+			name:   "receipt not found",
+			method: "GET",
+			path:   "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points",
+			status: http.StatusNotFound,
+			code:   "receipt_not_found",
+		},
+		{
+			name:   "endpoint not found",
+			method: "GET",
+			path:   "/unknown",
+			status: http.StatusNotFound,
+			code:   "endpoint_not_found",
+		},
+		{
+			name:   "invalid amount",
+			method: "POST",
+			path:   "/receipts/process",
+			body:   `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.0.0"}`,
+			status: http.StatusBadRequest,
+			code:   "invalid_amount",
+		},
+		{
+			name:   "invalid item price",
+			method: "POST",
+			path:   "/receipts/process",
+			body:   `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [{"shortDescription": "Pepsi", "price": "abc"}], "total": "1.00"}`,
+			status: http.StatusBadRequest,
+			code:   "invalid_amount",
+		},
+		{
+			name:   "invalid purchase date",
+			method: "POST",
+			path:   "/receipts/process",
+			body:   `{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01", "total": "1.00"}`,
+			status: http.StatusBadRequest,
+			code:   "invalid_date",
+		},
+		{
+			name:   "method not allowed",
+			method: "GET",
+			path:   "/receipts/process",
+			status: http.StatusMethodNotAllowed,
+			code:   "method_not_allowed",
+		},
+		{
+			name:   "malformed request",
+			method: "POST",
+			path:   "/receipts/process",
+			body:   `{`,
+			status: http.StatusBadRequest,
+			code:   "bad_request",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("status code does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if got.Code != tc.code {
+				t.Fatalf("error code does not match, got %q, want %q", got.Code, tc.code)
+			}
+			if got.Message == "" {
+				t.Fatalf("error message does not match, got empty, want message")
+			}
+		})
+	}
+}
+
+func TestGetPointsAccept(tt *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(tt, api, "testdata/readme-target-receipt.json")
+
+	for _, tc := range []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "default",
+			contentType: "application/json",
+			body:        "{\"points\":28,\"scored\":true}\n",
+		},
+		{
+			name:        "json",
+			accept:      "application/json",
+			contentType: "application/json",
+			body:        "{\"points\":28,\"scored\":true}\n",
+		},
+		{
+			name:        "plain text",
+			accept:      "text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body:        "28\n",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/receipts/"+id+"/points", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("status code does not match, got %d, want 200", rw.Code)
+			}
+			if got := rw.Header().Get("Content-Type"); got != tc.contentType {
+				t.Fatalf("content type does not match, got %q, want %q", got, tc.contentType)
+			}
+			if got := rw.Body.String(); got != tc.body {
+				t.Fatalf("points body does not match, got %q, want %q", got, tc.body)
+			}
+		})
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	api := NewAPI()
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/process/", bytes.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to process receipt, got %d status code, want 200", rw.Code)
+	}
+
+	var resp ProcessReceiptResponse
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to parse process receipt response, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: "GET", path: fmt.Sprintf("/receipts/%s/points/", resp.ID), status: http.StatusOK, body: "{\"points\":31,\"scored\":true}\n"},
+		{method: "GET", path: fmt.Sprintf("/receipts/%s/points//", resp.ID), status: http.StatusOK, body: "{\"points\":31,\"scored\":true}\n"},
+		{method: "GET", path: "/receipts/unknown/points/", status: http.StatusNotFound, body: "{\"error\":\"no receipt with ID \\\"unknown\\\" exists\",\"code\":\"receipt_not_found\"}\n"},
+		{method: "GET", path: "/receipts/process/", status: http.StatusMethodNotAllowed, body: "{\"error\":\"invalid request method, must be 'POST'\",\"code\":\"method_not_allowed\"}\n"},
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != tc.status {
+			t.Fatalf("%s %s status code does not match, got %d, want %d", tc.method, tc.path, rw.Code, tc.status)
+		}
+		if got := rw.Body.String(); got != tc.body {
+			t.Fatalf("%s %s response does not match, got %q, want %q", tc.method, tc.path, got, tc.body)
+		}
+	}
+}
+
+func TestOptions(tt *testing.T) {
+	api := NewAPI()
+
+	for _, tc := range []struct {
+		path  string
+		allow string
+	}{
+		{path: "/receipts/process", allow: "POST, OPTIONS"},
+		{path: "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points", allow: "GET, PUT, OPTIONS"},
+		{path: "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310", allow: "DELETE, GET, PUT, OPTIONS"},
+		{path: "/receipts", allow: "GET, OPTIONS"},
+		{path: "/healthz", allow: "GET, OPTIONS"},
+	} {
+		tt.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("OPTIONS", tc.path, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusNoContent {
+				t.Fatalf("status code does not match, got %d, want 204", rw.Code)
+			}
+			if got := rw.Header().Get("Allow"); got != tc.allow {
+				t.Fatalf("allow header does not match, got %q, want %q", got, tc.allow)
+			}
+		})
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/nonexistent", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("unknown route status does not match, got %d, want 404", rw.Code)
+	}
+
+	if ct := rw.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unknown route content type does not match, got %q, want %q", ct, "application/json")
+	}
+
+	var got Error
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse error response, got %v, want no error", err)
+	}
+
+	if !strings.Contains(got.Message, "/nonexistent") {
+		t.Fatalf("error message does not match, got %q, want it to contain %q", got.Message, "/nonexistent")
+	}
+}
+
+func TestProcessReceiptIfNoneMatch(t *testing.T) {
+	api := NewAPI()
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := json.Unmarshal(body, &prreq); err != nil {
+		t.Fatalf("failed to parse receipt file, got %v, want no error", err)
+	}
+	slices.Reverse(prreq.Items)
+	prreq.Retailer = "  " + prreq.Retailer + "  "
+	reordered := receiptJSON(t, &prreq)
+
+	var hash string
+	for _, tc := range []struct {
+		name string
+		body string
+		hash func() string
+		want int
+	}{
+		{name: "first", body: string(body), want: http.StatusOK},
+		{name: "duplicate", body: string(body), want: http.StatusPreconditionFailed},
+		{name: "reordered duplicate", body: reordered, want: http.StatusPreconditionFailed},
+		{name: "matching hash", body: reordered, hash: func() string { return hash }, want: http.StatusPreconditionFailed},
+		{name: "mismatched hash", body: string(body), hash: func() string { return "abc123" }, want: http.StatusBadRequest},
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(tc.body))
+		req.Header.Set("If-None-Match", "*")
+		if tc.hash != nil {
+			req.Header.Set(ContentHashHeader, tc.hash())
+		}
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != tc.want {
+			t.Fatalf("%s conditional process receipt status does not match, got %d, want %d", tc.name, rw.Code, tc.want)
+		}
+
+		if tc.name == "first" {
+			var resp ProcessReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to parse process receipt response, got %v, want no error", err)
+			}
+
+			crw := httptest.NewRecorder()
+			api.ServeHTTP(crw, httptest.NewRequest("GET", "/receipts/"+resp.ID+"/canonical", nil))
+			hash = crw.Header().Get(ContentHashHeader)
+		}
+	}
+
+	if n := countReceipts(t, api); n != 1 {
+		t.Fatalf("stored receipt count does not match, got %d, want 1", n)
+	}
+}
+
+func TestProcessReceiptIfNoneMatchSharedStore(t *testing.T) {
+	// The content hashes of processed receipts are found in the store, e.g.
+	// after a restart or on another API instance sharing the store.
+	store := NewMemoryStore()
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusPreconditionFailed} {
+		api := NewAPI(WithStore(store))
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+		req.Header.Set("If-None-Match", "*")
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != want {
+			t.Fatalf("conditional process receipt %d status does not match, got %d, want %d", i, rw.Code, want)
+		}
+	}
+}
+
+func TestProcessReceiptIfNoneMatchDeleted(tt *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		restart bool
+	}{
+		{name: "soft delete"},
+		{name: "soft delete after restart", restart: true},
+		{name: "hard delete", opts: []Option{WithHardDelete()}},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewMemoryStore()
+			api := NewAPI(append(tc.opts, WithStore(store))...)
+
+			process := func(t *testing.T, want int) {
+				t.Helper()
+
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+				req.Header.Set("If-None-Match", "*")
+
+				api.ServeHTTP(rw, req)
+
+				if rw.Code != want {
+					t.Fatalf("conditional process receipt status does not match, got %d, want %d", rw.Code, want)
+				}
+			}
+
+			id := processReceiptBody(t, api, string(body))
+
+			rw := httptest.NewRecorder()
+			api.ServeHTTP(rw, httptest.NewRequest("DELETE", "/receipts/"+id, nil))
+
+			if rw.Code != http.StatusNoContent {
+				t.Fatalf("failed to delete receipt, got %d status code, want 204", rw.Code)
+			}
+
+			if tc.restart {
+				api = NewAPI(append(tc.opts, WithStore(store))...)
+			}
+
+			// Deleting the receipt releases its content hash, so the receipt
+			// is stored again, once.
+			process(t, http.StatusOK)
+			process(t, http.StatusPreconditionFailed)
+		})
+	}
+}
+
+func TestDeleteReceipt(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		status  int
+		exports map[string]int
+	}{
+		{
+			name:   "soft delete",
+			status: http.StatusGone,
+			exports: map[string]int{
+				"/receipts/export.csv":                     1,
+				"/receipts/export.csv?includeDeleted=true": 2,
+			},
+		},
+		{
+			name:   "hard delete",
+			opts:   []Option{WithHardDelete()},
+			status: http.StatusNotFound,
+			exports: map[string]int{
+				"/receipts/export.csv":                     1,
+				"/receipts/export.csv?includeDeleted=true": 1,
+			},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+			processReceipt(t, api, "testdata/simple-receipt.json")
+
+			{
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("DELETE", fmt.Sprintf("/receipts/%s", id), nil)
+
+				api.ServeHTTP(rw, req)
+
+				if rw.Code != http.StatusNoContent {
+					t.Fatalf("failed to delete receipt, got %d status code, want 204", rw.Code)
+				}
+			}
+
+			{
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", id), nil)
+
+				api.ServeHTTP(rw, req)
+
+				if rw.Code != tc.status {
+					t.Fatalf("deleted receipt points status does not match, got %d, want %d", rw.Code, tc.status)
+				}
+			}
+
+			for path, want := range tc.exports {
+				rw := httptest.NewRecorder()
+				req := httptest.NewRequest("GET", path, nil)
+
+				api.ServeHTTP(rw, req)
+
+				records, err := csv.NewReader(rw.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("failed to parse exported CSV, got %v, want no error", err)
+				}
+
+				// Account for the header row.
+				if got := len(records) - 1; got != want {
+					t.Fatalf("exported receipt count for %q does not match, got %d, want %d", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreErrors(tt *testing.T) {
+	storeErr := errors.New("store unavailable")
+
+	for _, tc := range []struct {
+		name   string
+		store  *errStore
+		method string
+		path   string
+		body   string
+	}{
+		{
+			name:   "save error",
+			store:  &errStore{Store: NewMemoryStore(), saveErr: storeErr},
+			method: "POST",
+			path:   "/receipts/process",
+			body:   `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`,
+		},
+		{
+			name:   "get error",
+			store:  &errStore{Store: NewMemoryStore(), getErr: storeErr},
+			method: "GET",
+			path:   "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			api := NewAPI(WithStore(tc.store), WithErrorLog(log.New(&buf, "", 0)))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusInternalServerError {
+				t.Fatalf("store error status does not match, got %d, want 500", rw.Code)
+			}
+
+			var got Error
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+
+			if !strings.Contains(buf.String(), storeErr.Error()) {
+				t.Fatalf("store error was not logged, got %q, want it to contain %q", buf.String(), storeErr)
+			}
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	storeErr := errors.New("store unavailable")
+
+	handler := &captureHandler{}
+	api := NewAPI(
+		WithStore(&errStore{Store: NewMemoryStore(), getErr: storeErr}),
+		WithLogger(slog.New(handler)),
+	)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusInternalServerError {
+		t.Fatalf("store error status does not match, got %d, want 500", rw.Code)
+	}
+
+	if len(handler.records) != 1 {
+		t.Fatalf("log record count does not match, got %d, want 1", len(handler.records))
+	}
+
+	record := handler.records[0]
+	if record.Level != slog.LevelError {
+		t.Fatalf("log record level does not match, got %v, want %v", record.Level, slog.LevelError)
+	}
+	if record.Message != "store failure" {
+		t.Fatalf("log record message does not match, got %q, want %q", record.Message, "store failure")
+	}
+
+	attrs := make(map[string]any)
+	record.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Any()
+		return true
+	})
+
+	if got := attrs["receipt"]; got != "7fb1377b-b223-49d9-a31a-5a02701dd310" {
+		t.Fatalf("log record receipt does not match, got %v, want %q", got, "7fb1377b-b223-49d9-a31a-5a02701dd310")
+	}
+	if got := attrs["error"]; got != storeErr {
+		t.Fatalf("log record error does not match, got %v, want %v", got, storeErr)
+	}
+}
+
+// captureHandler is a [slog.Handler] that captures the records it handles.
+type captureHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *captureHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, record)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *captureHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// errStore is a [Store] that returns the configured errors from its methods,
+// delegating to the embedded store otherwise.
+type errStore struct {
+	Store
+	saveErr   error
+	getErr    error
+	healthErr error
+}
+
+func (s *errStore) Create(ctx context.Context, receipt *Receipt) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+
+	return s.Store.Create(ctx, receipt)
+}
+
+func (s *errStore) Save(ctx context.Context, receipt *Receipt) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+
+	return s.Store.Save(ctx, receipt)
+}
+
+func (s *errStore) HealthCheck(ctx context.Context) error {
+	if s.healthErr != nil {
+		return s.healthErr
+	}
+
+	return s.Store.HealthCheck(ctx)
+}
+
+func (s *errStore) Get(ctx context.Context, id string) (*Receipt, error) {
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+
+	return s.Store.Get(ctx, id)
+}
+
+// receiptJSON encodes the process receipt request as JSON.
+func receiptJSON(t *testing.T, prreq *ProcessReceiptRequest) string {
+	t.Helper()
+
+	b, err := json.Marshal(prreq)
+	if err != nil {
+		t.Fatalf("failed to encode receipt, got %v, want no error", err)
+	}
+
+	return string(b)
+}
+
+// processReceipt submits the receipt at path to the API and returns the ID of
+// the processed receipt.
+func processReceipt(t *testing.T, api *API, path string) string {
+	t.Helper()
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	return processReceiptBody(t, api, string(body))
+}
+
+// processReceiptBody submits the receipt JSON body to the API and returns the
+// ID of the processed receipt.
+func processReceiptBody(t *testing.T, api *API, body string) string {
+	t.Helper()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		b, _ := io.ReadAll(rw.Body)
+		t.Fatalf("failed to process receipt, got %d status code (%s), want 200", rw.Code, b)
+	}
+
+	var got ProcessReceiptResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse receipt response, got %v, want no error", err)
+	}
+
+	return got.ID
+}
+
+// getPoints returns the points of the receipt with the given ID from the API.
+func getPoints(t *testing.T, api *API, id string) int {
+	t.Helper()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get points, got %d status code, want 200", rw.Code)
+	}
+
+	var got GetPointsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse points response, got %v, want no error", err)
+	}
+
+	return got.Points
+}
+
+// countReceipts returns the number of receipts stored by the API.
+func countReceipts(t *testing.T, api *API) int {
+	t.Helper()
+
+	receipts, err := api.store.List(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list receipts, got %v, want no error", err)
+	}
+
+	return len(receipts)
+}
+
+func TestClose(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		timeout time.Duration
+		release time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "drained within deadline",
+			timeout: 5 * time.Second,
+			release: 50 * time.Millisecond,
+		},
+		{
+			name:    "deadline exceeded",
+			timeout: 50 * time.Millisecond,
+			release: 5 * time.Second,
+			wantErr: true,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+
+			api := NewAPI()
+			api.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+					if req.URL.Path == "/slow" {
+						close(started)
+						<-release
+					}
+					next.ServeHTTP(rw, req)
+				})
+			})
+
+			go api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			errc := make(chan error, 1)
+			go func() { errc <- api.Close(ctx) }()
+
+			// Wait for Close to begin rejecting new requests.
+			for {
+				rw := httptest.NewRecorder()
+				api.ServeHTTP(rw, httptest.NewRequest("GET", "/version", nil))
+				if rw.Code == http.StatusServiceUnavailable {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			time.AfterFunc(tc.release, func() { close(release) })
+
+			err := <-errc
+			if got, want := err != nil, tc.wantErr; got != want {
+				t.Fatalf("close error does not match, got %v, want error %v", err, want)
+			}
+			if tc.wantErr && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("close error does not match, got %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxBatchPointsIDs is the maximum number of receipt IDs accepted by the
+// [BatchPoints] endpoint in a single request.
+const maxBatchPointsIDs = 1000
+
+// BatchPointsRequest is the request body that is submitted to the
+// [BatchPoints] endpoint.
+type BatchPointsRequest struct {
+	// IDs are the unique IDs of the receipts to return the points of.
+	IDs []string `json:"ids"`
+}
+
+// BatchPointsResponse is the response body that is returned from the
+// [BatchPoints] endpoint.
+type BatchPointsResponse struct {
+	// Results are the points of each requested receipt, keyed by ID.
+	Results map[string]BatchPointsResult `json:"results"`
+}
+
+// BatchPointsResult is the result for a single receipt in
+// [BatchPointsResponse].
+type BatchPointsResult struct {
+	// Points are the number of Fetch rewards points assigned to the receipt,
+	// if found.
+	Points *int `json:"points,omitempty"`
+	// Error is "not_found" if no receipt exists for the ID, or "deleted" if
+	// the receipt has been deleted.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchPoints is an [http.HandlerFunc] that returns the point values for
+// multiple receipts at once, avoiding a request per receipt when polling
+// points for many receipts.
+func (api *API) BatchPoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	var bpreq BatchPointsRequest
+	if err := api.decode(req.Body, &bpreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse batch points request, %v", err)
+		return
+	}
+
+	if len(bpreq.IDs) > maxBatchPointsIDs {
+		api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid batch points request, must have at most %d IDs, got %d", maxBatchPointsIDs, len(bpreq.IDs))
+		return
+	}
+
+	receipts, err := api.store.GetMany(req.Context(), bpreq.IDs)
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to get receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to get receipts")
+		return
+	}
+
+	resp := BatchPointsResponse{
+		Results: make(map[string]BatchPointsResult, len(bpreq.IDs)),
+	}
+
+	for _, id := range bpreq.IDs {
+		receipt, ok := receipts[id]
+
+		switch {
+		case !ok:
+			resp.Results[id] = BatchPointsResult{Error: "not_found"}
+		case receipt.Deleted:
+			resp.Results[id] = BatchPointsResult{Error: "deleted"}
+		default:
+			points := api.points(receipt)
+			resp.Results[id] = BatchPointsResult{Points: &points}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&resp)
+}
+
+// maxBatchReceipts is the maximum number of receipts accepted by the
+// [BatchProcessReceipts] endpoint in a single request.
+const maxBatchReceipts = 100
+
+// BatchProcessRequest is the request body that is submitted to the
+// [BatchProcessReceipts] endpoint.
+type BatchProcessRequest struct {
+	// Receipts are the receipts to process, each in the format of a
+	// [ProcessReceiptRequest].
+	Receipts []json.RawMessage `json:"receipts"`
+}
+
+// BatchProcessResponse is the response body that is returned from the
+// [BatchProcessReceipts] endpoint.
+type BatchProcessResponse struct {
+	// Results are the results of processing each submitted receipt, in the
+	// order submitted.
+	Results []BatchProcessResult `json:"results"`
+	// Summary summarizes the results.
+	Summary BatchProcessSummary `json:"summary"`
+}
+
+// BatchProcessSummary is the summary of the results of a
+// [BatchProcessResponse].
+type BatchProcessSummary struct {
+	// Submitted is the number of receipts submitted, including duplicates.
+	Submitted int `json:"submitted"`
+	// Succeeded is the number of receipts processed and stored.
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of receipts rejected with an error.
+	Failed int `json:"failed"`
+	// Points is the total of the points awarded to the stored receipts.
+	Points int `json:"points"`
+}
+
+// BatchProcessResult is the result of processing a single receipt in
+// [BatchProcessResponse].
+type BatchProcessResult struct {
+	// ID is the unique ID of the stored receipt, if processed.
+	ID string `json:"id,omitempty"`
+	// Points are the number of Fetch rewards points awarded to the receipt,
+	// if processed.
+	Points *int `json:"points,omitempty"`
+	// Warnings are the warnings about the receipt, as in
+	// [ProcessReceiptResponse].
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is the reason the receipt was rejected, if not processed.
+	Error *Error `json:"error,omitempty"`
+}
+
+// BatchProcessReceipts is an [http.HandlerFunc] that processes multiple
+// receipts at once, as the [ProcessReceipt] endpoint, avoiding a request per
+// receipt when submitting many receipts. Each receipt is processed and stored
+// independently, so a rejected receipt does not prevent the others from being
+// stored, and the response summarizes the results.
+//
+// Receipts may not be fetched by `receiptUrl` in a batch.
+func (api *API) BatchProcessReceipts(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	if api.processSlots != nil {
+		select {
+		case api.processSlots <- struct{}{}:
+			defer func() { <-api.processSlots }()
+		default:
+			rw.Header().Set("Retry-After", "1")
+			api.errorWithCode(rw, req, http.StatusServiceUnavailable, "too_many_receipts", "too many receipts being processed, retry later")
+			return
+		}
+	}
+
+	var bpreq BatchProcessRequest
+	if err := api.decode(req.Body, &bpreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse batch process request, %v", err)
+		return
+	}
+
+	if len(bpreq.Receipts) > maxBatchReceipts {
+		api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid batch process request, must have at most %d receipts, got %d", maxBatchReceipts, len(bpreq.Receipts))
+		return
+	}
+
+	resp := BatchProcessResponse{
+		Results: make([]BatchProcessResult, 0, len(bpreq.Receipts)),
+	}
+
+	for _, raw := range bpreq.Receipts {
+		result := api.batchProcessReceipt(req, raw)
+
+		resp.Summary.Submitted++
+		if result.Error != nil {
+			api.countFailure(result.Error.Code)
+			resp.Summary.Failed++
+		} else {
+			api.processed.succeeded.Add(1)
+			resp.Summary.Succeeded++
+			resp.Summary.Points += *result.Points
+		}
+
+		resp.Results = append(resp.Results, result)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&resp)
+}
+
+// batchProcessReceipt processes and stores a single JSON encoded receipt of a
+// batch, returning its result.
+func (api *API) batchProcessReceipt(req *http.Request, raw json.RawMessage) BatchProcessResult {
+	var prreq ProcessReceiptRequest
+	if err := api.decodeReceipt(bytes.NewReader(raw), &prreq); err != nil {
+		return batchProcessError("malformed_request", "failed to parse receipt, %v", err)
+	}
+
+	if prreq.ReceiptURL != "" {
+		return batchProcessError("invalid_receipt_url", "invalid receipt, receiptUrl is not supported in a batch")
+	}
+
+	receipt, warnings, err := api.receiptFrom(&prreq, nil)
+	if err != nil {
+		return batchProcessError(receiptErrorReason(err), "invalid receipt, %v", err)
+	}
+
+	if receipt.ContentHash, err = api.contentHash(receipt); err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to hash receipt", "error", err)
+		return batchProcessError("hash_failure", "failed to hash receipt")
+	}
+
+	err = api.storeNew(req.Context(), receipt, false)
+
+	var quotaErr *quotaExceededError
+	if errors.As(err, &quotaErr) {
+		return batchProcessError("daily_quota_exceeded", "%v", err)
+	}
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to store receipt", "error", err)
+		return batchProcessError("store_failure", "failed to store receipt")
+	}
+
+	return BatchProcessResult{
+		ID:       receipt.ID,
+		Points:   &receipt.Points,
+		Warnings: warnings,
+	}
+}
+
+// batchProcessError returns the result of a receipt of a batch rejected with
+// the error code and message.
+func batchProcessError(code, format string, args ...any) BatchProcessResult {
+	return BatchProcessResult{
+		Error: &Error{
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		},
+	}
+}
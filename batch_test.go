@@ -0,0 +1,141 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchPoints(t *testing.T) {
+	api := NewAPI()
+
+	target := processReceipt(t, api, "testdata/readme-target-receipt.json")
+	simple := processReceipt(t, api, "testdata/simple-receipt.json")
+
+	body := `{"ids": ["` + target + `", "unknown", "` + simple + `"]}`
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/points:batch", strings.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get batch points, got %d status code, want 200", rw.Code)
+	}
+
+	var got BatchPointsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse batch points response, got %v, want no error", err)
+	}
+
+	if len(got.Results) != 3 {
+		t.Fatalf("batch points result count does not match, got %d, want 3", len(got.Results))
+	}
+
+	for id, want := range map[string]int{target: 28, simple: 31} {
+		if r := got.Results[id]; r.Points == nil || *r.Points != want {
+			t.Fatalf("batch points result of %q does not match, got %+v, want %d points", id, r, want)
+		}
+	}
+
+	if r := got.Results["unknown"]; r.Points != nil || r.Error != "not_found" {
+		t.Fatalf("batch points result of unknown ID does not match, got %+v, want not_found", r)
+	}
+}
+
+func TestBatchProcessReceipts(t *testing.T) {
+	api := NewAPI()
+
+	target, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+	simple, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	// The target receipt is submitted twice and counted, and stored, twice.
+	receipts := []string{
+		string(target),
+		`{"retailer": 5}`,
+		string(simple),
+		`{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01", "items": [], "total": "1.00"}`,
+		string(target),
+	}
+	body := `{"receipts": [` + strings.Join(receipts, ", ") + `]}`
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/batch", strings.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to process batch, got %d status code, want 200", rw.Code)
+	}
+
+	var got BatchProcessResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse batch process response, got %v, want no error", err)
+	}
+
+	if len(got.Results) != len(receipts) {
+		t.Fatalf("batch process result count does not match, got %d, want %d", len(got.Results), len(receipts))
+	}
+
+	for i, want := range []int{28, -1, 31, -1, 28} {
+		r := got.Results[i]
+
+		if want < 0 {
+			if r.Error == nil || r.ID != "" || r.Points != nil {
+				t.Fatalf("batch process result %d does not match, got %+v, want error", i, r)
+			}
+			continue
+		}
+
+		if r.Error != nil || r.Points == nil || *r.Points != want {
+			t.Fatalf("batch process result %d does not match, got %+v, want %d points", i, r, want)
+		}
+		if points := getPoints(t, api, r.ID); points != want {
+			t.Fatalf("stored receipt points of result %d do not match, got %d, want %d", i, points, want)
+		}
+	}
+
+	if code := got.Results[1].Error.Code; code != "malformed_request" {
+		t.Fatalf("malformed receipt error code does not match, got %q, want malformed_request", code)
+	}
+
+	want := BatchProcessSummary{Submitted: 5, Succeeded: 3, Failed: 2, Points: 28 + 31 + 28}
+	if got.Summary != want {
+		t.Fatalf("batch process summary does not match, got %+v, want %+v", got.Summary, want)
+	}
+
+	if n := countReceipts(t, api); n != 3 {
+		t.Fatalf("stored receipt count does not match, got %d, want 3", n)
+	}
+}
+
+func TestBatchProcessReceiptsMaxReceipts(t *testing.T) {
+	api := NewAPI()
+
+	receipts := make([]string, maxBatchReceipts+1)
+	for i := range receipts {
+		receipts[i] = "{}"
+	}
+	body := `{"receipts": [` + strings.Join(receipts, ", ") + `]}`
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/batch", strings.NewReader(body))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("batch process status does not match, got %d, want 422", rw.Code)
+	}
+	if n := countReceipts(t, api); n != 0 {
+		t.Fatalf("stored receipt count does not match, got %d, want 0", n)
+	}
+}
@@ -0,0 +1,124 @@
+package fetch
+
+import (
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// canonicalReceipt is the canonical form of the content of a receipt, see
+// [CanonicalJSON]. The order of the fields is part of the canonical form.
+type canonicalReceipt struct {
+	Retailer        string          `json:"retailer"`
+	Purchased       string          `json:"purchased"`
+	Items           []canonicalItem `json:"items"`
+	Total           int             `json:"total"`
+	DiscountPercent int             `json:"discountPercent"`
+	DiscountAmount  int             `json:"discountAmount"`
+}
+
+// canonicalItem is the canonical form of a receipt item.
+type canonicalItem struct {
+	Description string `json:"description"`
+	Price       int    `json:"price"`
+}
+
+// CanonicalJSON returns the canonical JSON encoding of the content of the
+// receipt, the canonicalization the server hashes to deduplicate processed
+// receipts, so that clients can compute idempotency or deduplication hashes
+// which agree with the server for receipts with the same content. In the
+// canonical form:
+//
+//   - The retailer name and item descriptions have leading and trailing
+//     whitespace removed and internal runs of whitespace collapsed to a single
+//     space.
+//   - The purchase date and time are formatted as "2006-01-02T15:04".
+//   - Items are sorted by description, then by price.
+//   - Amounts are integer cents, and the discount is a percentage and an
+//     amount, either of which may be zero.
+//   - Fields are in a fixed order with no insignificant whitespace.
+//
+// The ID, points, metadata, and other server-assigned fields of the receipt
+// are not part of its content and are excluded.
+func CanonicalJSON(receipt *Receipt) ([]byte, error) {
+	canonical := canonicalReceipt{
+		Retailer:        collapseWhitespace(receipt.Retailer),
+		Purchased:       receipt.Purchased.UTC().Format("2006-01-02T15:04"),
+		Items:           make([]canonicalItem, 0, len(receipt.Items)),
+		Total:           receipt.Total,
+		DiscountPercent: receipt.Discount.Percent,
+		DiscountAmount:  receipt.Discount.Amount,
+	}
+
+	for _, item := range receipt.Items {
+		canonical.Items = append(canonical.Items, canonicalItem{
+			Description: collapseWhitespace(item.Description),
+			Price:       item.Price,
+		})
+	}
+
+	slices.SortFunc(canonical.Items, func(a, b canonicalItem) int {
+		return cmp.Or(strings.Compare(a.Description, b.Description), cmp.Compare(a.Price, b.Price))
+	})
+
+	return json.Marshal(&canonical)
+}
+
+// collapseWhitespace trims leading and trailing whitespace from s and
+// collapses internal runs of whitespace to a single space.
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// contentHash returns the hex encoded hash of the [CanonicalJSON] of the
+// receipt using the hash function of the API, used to deduplicate receipts.
+func (api *API) contentHash(receipt *Receipt) (string, error) {
+	b, err := CanonicalJSON(receipt)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(api.hash(b)), nil
+}
+
+// GetCanonicalReceipt is an [http.HandlerFunc] that returns the
+// [CanonicalJSON] of the receipt specified by the `id` path parameter. The
+// [ContentHashHeader] of the response is the hex encoded hash of the
+// canonical JSON, using the hash function of the API, see [WithHash].
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`, or `410 Gone` if the receipt has been deleted.
+func (api *API) GetCanonicalReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := api.store.Get(req.Context(), id)
+	if err == nil && receipt.Deleted {
+		err = errDeleted
+	}
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	b, err := CanonicalJSON(receipt)
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to encode canonical receipt", "receipt", id, "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to encode canonical receipt with ID %q", id)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set(ContentHashHeader, hex.EncodeToString(api.hash(b)))
+	rw.Write(b)
+}
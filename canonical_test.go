@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	purchased := time.Date(2022, 1, 1, 13, 1, 0, 0, time.UTC)
+
+	a := &Receipt{
+		ID:        "a",
+		Retailer:  "Target",
+		Purchased: purchased,
+		Items: []ReceiptItem{
+			{Description: "Mountain Dew 12PK", Price: 649},
+			{Description: "Emils Cheese Pizza", Price: 1225},
+			{Description: "Knorr Creamy Chicken", Price: 126},
+		},
+		Total:  2000,
+		Points: 28,
+	}
+
+	b := &Receipt{
+		ID:        "b",
+		Retailer:  "  Target ",
+		Purchased: purchased,
+		Items: []ReceiptItem{
+			{Description: "Knorr  Creamy Chicken", Price: 126},
+			{Description: "Mountain Dew 12PK ", Price: 649},
+			{Description: "Emils Cheese Pizza", Price: 1225},
+		},
+		Total:    2000,
+		Points:   100,
+		Metadata: map[string]string{"source": "scan"},
+	}
+
+	got, err := CanonicalJSON(a)
+	if err != nil {
+		t.Fatalf("failed to encode canonical receipt, got %v, want no error", err)
+	}
+
+	want := `{"retailer":"Target","purchased":"2022-01-01T13:01","items":[{"description":"Emils Cheese Pizza","price":1225},{"description":"Knorr Creamy Chicken","price":126},{"description":"Mountain Dew 12PK","price":649}],"total":2000,"discountPercent":0,"discountAmount":0}`
+	if string(got) != want {
+		t.Fatalf("canonical receipt does not match, got %s, want %s", got, want)
+	}
+
+	other, err := CanonicalJSON(b)
+	if err != nil {
+		t.Fatalf("failed to encode canonical receipt, got %v, want no error", err)
+	}
+
+	if string(other) != string(got) {
+		t.Fatalf("canonical receipts differing in item order do not match, got %s, want %s", other, got)
+	}
+
+	b.Items[0].Price = 127
+
+	if changed, _ := CanonicalJSON(b); string(changed) == string(got) {
+		t.Fatalf("canonical receipts differing in content match, got %s for both", changed)
+	}
+}
+
+func TestGetCanonicalReceipt(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	receipt, err := api.store.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get receipt, got %v, want no error", err)
+	}
+
+	want, err := CanonicalJSON(receipt)
+	if err != nil {
+		t.Fatalf("failed to encode canonical receipt, got %v, want no error", err)
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/canonical", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get canonical receipt, got %d status code, want 200", rw.Code)
+	}
+
+	if got := rw.Body.String(); got != string(want) {
+		t.Fatalf("canonical receipt does not match, got %s, want %s", got, want)
+	}
+
+	sum := sha256.Sum256(want)
+	if got, want := rw.Header().Get(ContentHashHeader), hex.EncodeToString(sum[:]); got != want {
+		t.Fatalf("canonical receipt hash does not match, got %q, want %q", got, want)
+	}
+}
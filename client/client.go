@@ -0,0 +1,180 @@
+// Package client implements a Go client for the Fetch API server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/admtnnr/fetch"
+)
+
+// Defaults used by a [Client] when not otherwise configured.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 100 * time.Millisecond
+)
+
+// Client is a client for the Fetch API server.
+//
+// Requests that are rate limited by the server with `429 Too Many Requests`
+// are retried, honoring the `Retry-After` response header when present and
+// otherwise backing off exponentially.
+type Client struct {
+	// BaseURL is the base URL of the Fetch API server, e.g.
+	// "http://localhost:8080".
+	BaseURL string
+	// HTTPClient is the HTTP client used to make requests. If nil,
+	// [http.DefaultClient] is used.
+	HTTPClient *http.Client
+	// MaxAttempts is the maximum number of attempts made for a request,
+	// including the first. If zero, [DefaultMaxAttempts] is used.
+	MaxAttempts int
+	// Backoff is the delay before the first retry of a request without a
+	// `Retry-After` response header, doubling on each subsequent retry. If
+	// zero, [DefaultBackoff] is used.
+	Backoff time.Duration
+}
+
+// Error is returned by the [Client] when the server responds with an error.
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Message is the human-readable error message from the response body.
+	Message string
+	// Code is the machine-readable error code from the response body, if
+	// any, e.g. "receipt_not_found".
+	Code string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fetch API responded with %d status code, %s", e.Status, e.Message)
+}
+
+// New creates a new [Client] for the Fetch API server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: baseURL,
+	}
+}
+
+// ProcessReceipt submits the receipt for processing and returns the ID of the
+// processed receipt.
+func (c *Client) ProcessReceipt(ctx context.Context, receipt *fetch.ProcessReceiptRequest) (string, error) {
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode receipt, %w", err)
+	}
+
+	var resp fetch.ProcessReceiptResponse
+	if err := c.do(ctx, "POST", "/receipts/process", body, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.ID, nil
+}
+
+// GetPoints returns the points awarded to the receipt with the given ID.
+func (c *Client) GetPoints(ctx context.Context, id string) (int, error) {
+	var resp fetch.GetPointsResponse
+	if err := c.do(ctx, "GET", "/receipts/"+url.PathEscape(id)+"/points", nil, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Points, nil
+}
+
+// do makes the request, retrying rate limited requests, and decodes the JSON
+// response body into v.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, v any) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	maxAttempts := c.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create request, %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to %s %s, %w", method, path, err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxAttempts {
+			delay, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				delay = backoff << (attempt - 1)
+			}
+
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+
+			continue
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			var e fetch.Error
+			json.NewDecoder(resp.Body).Decode(&e)
+
+			return &Error{
+				Status:  resp.StatusCode,
+				Message: e.Message,
+				Code:    e.Code,
+			}
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return fmt.Errorf("failed to parse %s %s response, %w", method, path, err)
+		}
+
+		return nil
+	}
+}
+
+// retryAfter parses the value of a `Retry-After` header, either a number of
+// seconds or an HTTP date, into the delay before retrying.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
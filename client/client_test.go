@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/admtnnr/fetch"
+)
+
+func TestRetry(tt *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		retryAfter string
+	}{
+		{name: "retry after header", retryAfter: "0"},
+		{name: "exponential backoff"},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requests atomic.Int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if requests.Add(1) <= 2 {
+					if tc.retryAfter != "" {
+						rw.Header().Set("Retry-After", tc.retryAfter)
+					}
+					rw.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+
+				rw.Header().Set("Content-Type", "application/json")
+				rw.Write([]byte(`{"points": 28}`))
+			}))
+			defer srv.Close()
+
+			c := New(srv.URL)
+			c.Backoff = time.Millisecond
+
+			points, err := c.GetPoints(context.Background(), "abc")
+			if err != nil {
+				t.Fatalf("failed to get points, got %v, want no error", err)
+			}
+
+			if points != 28 {
+				t.Fatalf("receipt points do not match, got %d, want 28", points)
+			}
+
+			if n := requests.Load(); n != 3 {
+				t.Fatalf("request count does not match, got %d, want 3", n)
+			}
+		})
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL)
+	c.MaxAttempts = 2
+
+	_, err := c.ProcessReceipt(context.Background(), &fetch.ProcessReceiptRequest{})
+
+	var e *Error
+	if !errors.As(err, &e) || e.Status != http.StatusTooManyRequests {
+		t.Fatalf("exhausted retries error does not match, got %v, want 429 error", err)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Retry-After", "60")
+		rw.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := New(srv.URL).GetPoints(ctx, "abc"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("canceled retry error does not match, got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	srv := httptest.NewServer(fetch.NewAPI())
+	defer srv.Close()
+
+	c := New(srv.URL)
+
+	id, err := c.ProcessReceipt(context.Background(), &fetch.ProcessReceiptRequest{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-02",
+		PurchaseTime: "13:13",
+		Items: []fetch.ProcessReceiptItem{
+			{ShortDescription: "Pepsi - 12-oz", Price: "1.25"},
+		},
+		Total: "1.25",
+	})
+	if err != nil {
+		t.Fatalf("failed to process receipt, got %v, want no error", err)
+	}
+
+	points, err := c.GetPoints(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get points, got %v, want no error", err)
+	}
+
+	if points != 31 {
+		t.Fatalf("receipt points do not match, got %d, want 31", points)
+	}
+}
@@ -0,0 +1,149 @@
+package fetch
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ForwardedHeader is the header trusted proxies report the IP address of the
+// client of a request in. See [WithForwardedHeader].
+type ForwardedHeader int
+
+const (
+	// XForwardedFor reads the client from the `X-Forwarded-For` header. This
+	// is the default.
+	XForwardedFor ForwardedHeader = iota
+	// Forwarded reads the client from the `Forwarded` header defined by
+	// RFC 7239.
+	Forwarded
+)
+
+// WithTrustedProxies sets the proxies trusted to report the IP address of the
+// client of a request in the header set by [WithForwardedHeader], e.g. the
+// load balancers in front of the API, which identify clients for per-IP
+// limits such as [API.LimitDailyReceipts]. The header is ignored for requests
+// from other addresses, since it is trivially spoofed. By default no proxies
+// are trusted and the client is always the remote address of the connection.
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(api *API) {
+		api.trustedProxies = prefixes
+	}
+}
+
+// WithForwardedHeader sets the header trusted proxies report the IP address of
+// the client of a request in. Only that header is read, since a proxy which
+// sets one header passes the other through from the client unchanged.
+// Defaults to [XForwardedFor].
+func WithForwardedHeader(header ForwardedHeader) Option {
+	return func(api *API) {
+		api.forwardedHeader = header
+	}
+}
+
+// clientIP returns the IP address of the client of the request. If the remote
+// address of the request is a trusted proxy, the client is the nearest
+// untrusted address in the chain of forwarded addresses of the configured
+// header. If every forwarded address is trusted the client is the furthest
+// one.
+func (api *API) clientIP(req *http.Request) string {
+	remote := remoteIP(req)
+	if !api.trustedProxy(remote) {
+		return remote
+	}
+
+	var chain []string
+	switch api.forwardedHeader {
+	case Forwarded:
+		chain = forwardedFor(req.Header.Values("Forwarded"))
+	default:
+		chain = xForwardedFor(req.Header.Values("X-Forwarded-For"))
+	}
+
+	// Walk the chain from the nearest proxy, stopping at the first address
+	// which was not added by a trusted proxy.
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		client = chain[i]
+		if !api.trustedProxy(client) {
+			break
+		}
+	}
+
+	return client
+}
+
+// trustedProxy reports whether the IP address is a trusted proxy.
+func (api *API) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range api.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the IP address of the remote end of the connection of the
+// request, without the port.
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// xForwardedFor returns the addresses of the `X-Forwarded-For` header values,
+// from the furthest to the nearest.
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+
+	return chain
+}
+
+// forwardedFor returns the `for` addresses of the `Forwarded` header values
+// as defined by RFC 7239, from the furthest to the nearest, without quotes,
+// brackets, or ports, e.g. `for="[2001:db8::1]:4711"` as "2001:db8::1".
+// Elements without a `for` parameter are recorded as unknown.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			addr := "unknown"
+			for _, pair := range strings.Split(element, ";") {
+				key, val, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					addr = forwardedNode(strings.Trim(val, `"`))
+				}
+			}
+
+			chain = append(chain, addr)
+		}
+	}
+
+	return chain
+}
+
+// forwardedNode returns the IP address of the node of a `Forwarded` header,
+// e.g. "192.0.2.1:80" or "[2001:db8::1]:4711", without brackets or port.
+func forwardedNode(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
@@ -0,0 +1,125 @@
+package fetch
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(tt *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+
+	for _, tc := range []struct {
+		name       string
+		trusted    []netip.Prefix
+		header     ForwardedHeader
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "192.0.2.1:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "direct ipv6",
+			remoteAddr: "[2001:db8::1]:1234",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "untrusted by default",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "spoofed from untrusted client",
+			trusted:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "trusted proxy",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.1"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "trusted proxy chain",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 192.0.2.1, 10.0.0.2"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "all trusted",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "trusted proxy without header",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "forwarded",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:ffff::1]:4711"`},
+			want:       "192.0.2.60",
+		},
+		{
+			name:       "forwarded spoofed",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=1.1.1.1",
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name:       "x-forwarded-for spoofed",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=203.0.113.9",
+				"X-Forwarded-For": "1.1.1.1",
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name:       "forwarded unknown",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": `proto=https`},
+			want:       "unknown",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(WithTrustedProxies(tc.trusted...), WithForwardedHeader(tc.header))
+
+			req := httptest.NewRequest("POST", "/receipts/process", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := api.clientIP(req); got != tc.want {
+				t.Fatalf("client IP does not match, got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,17 +17,119 @@ import (
 )
 
 var (
-	port = flag.Int("port", 8080, "port of API server")
+	port                  = flag.Int("port", 8080, "port of API server, defaults to the PORT environment variable if set")
+	slowThreshold         = flag.Duration("slow-request-threshold", time.Second, "log requests taking longer than this duration, 0 disables")
+	accessLog             = flag.Bool("access-log", false, "write an access log of every request to stdout as JSON lines")
+	dailyQuota            = flag.Int("daily-receipt-quota", 0, "maximum receipts processed or imported per client IP per day, 0 disables")
+	totalTolerance        = flag.Int("strict-total-tolerance", -1, "reject receipts whose total differs from the sum of item prices by more than this many cents, negative disables")
+	trustedProxies        = flag.String("trusted-proxies", "", "comma separated IP addresses or CIDR prefixes of proxies trusted to report client IPs in the -forwarded-header header")
+	forwardedHeader       = flag.String("forwarded-header", "X-Forwarded-For", "header trusted proxies report client IPs in, one of: X-Forwarded-For, Forwarded")
+	pointsTTL             = flag.Duration("points-ttl", 0, "duration after which the points of processed receipts expire, 0 never expires")
+	debug                 = flag.Bool("debug", false, "enable debug endpoints, not for public use")
+	adminPort             = flag.Int("admin-port", 0, "port of the admin server, not for public use, 0 disables")
+	pprofToken            = flag.String("pprof-token", "", "bearer token of the /debug/pprof/ endpoints of the admin server, empty disables")
+	enableReset           = flag.Bool("enable-reset", false, "enable POST /admin/reset of the admin server to clear all receipts, for test environments only")
+	recalculate           = flag.Bool("dev-recalculate", false, "recalculate points on every fetch, for development only")
+	storeSpec             = flag.String("store", "memory", "store backend of receipts, one of: memory")
+	rulesetPath           = flag.String("ruleset", "", "path of a JSON file of the ruleset used to calculate points, defaults to the standard rules")
+	maintenanceRetryAfter = flag.Duration("maintenance-retry-after", 30*time.Second, "Retry-After of write endpoints in maintenance mode, toggled by SIGUSR2")
+	receiptURLHosts       = flag.String("receipt-url-hosts", "", "comma separated hosts, https on the default port, or origins, e.g. http://localhost:8080, receipts may be fetched from by receiptUrl, empty disables")
+	shutdownTimeout       = flag.Duration("shutdown-timeout", 10*time.Second, "maximum duration to wait for in-flight requests on shutdown")
 )
 
 func main() {
+	flag.Parse()
+
 	fmt.Fprintf(os.Stderr, "starting Fetch API server\n")
 
+	portSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "port" {
+			portSet = true
+		}
+	})
+
+	addrPort, err := resolvePort(*port, portSet, os.Getenv("PORT"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid port: %v\n", err)
+		os.Exit(2)
+	}
+
+	store, err := newStore(*storeSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid store: %v\n", err)
+		os.Exit(2)
+	}
+
 	ctx := context.Background()
-	api := fetch.NewAPI()
+	opts := []fetch.Option{fetch.WithStore(store)}
+	if *rulesetPath != "" {
+		ruleset, err := fetch.LoadRuleset(*rulesetPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid ruleset: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, fetch.WithRuleset(ruleset))
+	}
+	if *totalTolerance >= 0 {
+		opts = append(opts, fetch.WithStrictTotals(*totalTolerance))
+	}
+	if *trustedProxies != "" {
+		prefixes, err := parsePrefixes(*trustedProxies)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid trusted proxies: %v\n", err)
+			os.Exit(2)
+		}
+		header, err := parseForwardedHeader(*forwardedHeader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid forwarded header: %v\n", err)
+			os.Exit(2)
+		}
+		opts = append(opts, fetch.WithTrustedProxies(prefixes...), fetch.WithForwardedHeader(header))
+	}
+	if *pointsTTL > 0 {
+		opts = append(opts, fetch.WithPointsTTL(*pointsTTL))
+	}
+	if *debug {
+		opts = append(opts, fetch.WithDebug())
+	}
+	if *pprofToken != "" {
+		if *adminPort == 0 {
+			fmt.Fprintf(os.Stderr, "invalid admin port: -pprof-token requires -admin-port\n")
+			os.Exit(2)
+		}
+		opts = append(opts, fetch.WithProfiling(*pprofToken))
+	}
+	if *receiptURLHosts != "" {
+		opts = append(opts, fetch.WithReceiptURLHosts(strings.Split(*receiptURLHosts, ",")...))
+	}
+	if *enableReset {
+		if *adminPort == 0 {
+			fmt.Fprintf(os.Stderr, "invalid admin port: -enable-reset requires -admin-port\n")
+			os.Exit(2)
+		}
+		opts = append(opts, fetch.WithReset())
+	}
+	if *recalculate {
+		opts = append(opts, fetch.WithAlwaysRecalculate())
+	}
+
+	api := fetch.NewAPI(opts...)
+
+	if *accessLog {
+		api.Use(fetch.LogAccessJSON(os.Stdout))
+	}
+	if *dailyQuota > 0 {
+		api.Use(api.LimitDailyReceipts(*dailyQuota))
+	}
+	if *slowThreshold > 0 {
+		api.Use(api.LogSlowRequests(*slowThreshold))
+	}
 
+	// The streaming endpoints, e.g. import, export, and listing, clear the
+	// timeouts for their requests so that they are not cut off.
 	srv := http.Server{
-		Addr:         fmt.Sprintf(":%d", *port),
+		Addr:         fmt.Sprintf(":%d", addrPort),
 		Handler:      api,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
@@ -32,15 +137,129 @@ func main() {
 
 	go srv.ListenAndServe()
 
+	// The admin server has no write timeout so that CPU profiles and traces
+	// may be captured over longer durations.
+	var admin *http.Server
+	if *adminPort != 0 {
+		admin = &http.Server{
+			Addr:        fmt.Sprintf(":%d", *adminPort),
+			Handler:     api.AdminHandler(),
+			ReadTimeout: 5 * time.Second,
+		}
+
+		go admin.ListenAndServe()
+	}
+
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGTERM)
 
-	<-sigc
+	// SIGUSR2 toggles maintenance mode, all other signals shut down the
+	// server.
+	for sig := range sigc {
+		if sig != syscall.SIGUSR2 {
+			break
+		}
+
+		if api.InMaintenance() {
+			fmt.Fprintf(os.Stderr, "exiting maintenance mode\n")
+			api.ExitMaintenance()
+		} else {
+			fmt.Fprintf(os.Stderr, "entering maintenance mode\n")
+			api.EnterMaintenance(*maintenanceRetryAfter)
+		}
+	}
 
 	fmt.Fprintf(os.Stderr, "shutting down Fetch API server\n")
 
+	ctx, cancel := context.WithTimeout(ctx, *shutdownTimeout)
+	defer cancel()
+
+	// Close the API first so that requests arriving during shutdown are
+	// rejected with 503 while in-flight requests drain.
+	if err := api.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to drain Fetch API server: %v\n", err)
+	}
+
+	if admin != nil {
+		admin.Close()
+	}
+
 	if err := srv.Shutdown(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to shutdown Fetch API server: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// resolvePort returns the port of the API server, preferring the -port flag
+// if set, then the PORT environment variable if set, then the -port flag
+// default.
+func resolvePort(flagPort int, flagSet bool, env string) (int, error) {
+	if flagSet || env == "" {
+		if flagPort < 1 || flagPort > 65535 {
+			return 0, fmt.Errorf("-port %d must be between 1 and 65535", flagPort)
+		}
+		return flagPort, nil
+	}
+
+	p, err := strconv.Atoi(env)
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("PORT %q must be a number between 1 and 65535", env)
+	}
+
+	return p, nil
+}
+
+// parsePrefixes parses a comma separated list of IP addresses or CIDR
+// prefixes, e.g. "10.0.0.0/8,192.0.2.1". Addresses are parsed as prefixes of
+// the single address.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+
+		if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR prefix", s)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// parseForwardedHeader parses the name of the header trusted proxies report
+// client IPs in, case insensitively.
+func parseForwardedHeader(name string) (fetch.ForwardedHeader, error) {
+	switch {
+	case strings.EqualFold(name, "X-Forwarded-For"):
+		return fetch.XForwardedFor, nil
+	case strings.EqualFold(name, "Forwarded"):
+		return fetch.Forwarded, nil
+	default:
+		return 0, fmt.Errorf("%q is not one of X-Forwarded-For, Forwarded", name)
+	}
+}
+
+// newStore creates the store backend described by spec, which is the name of
+// the backend optionally followed by a colon and backend specific
+// configuration, e.g. "memory" or "sqlite:receipts.db".
+func newStore(spec string) (fetch.Store, error) {
+	name, config, _ := strings.Cut(spec, ":")
+
+	switch name {
+	case "memory":
+		if config != "" {
+			return nil, fmt.Errorf("memory store does not take configuration, got %q", spec)
+		}
+		return fetch.NewMemoryStore(), nil
+	case "sqlite", "file":
+		return nil, fmt.Errorf("%s store is not supported by this build, must be one of: memory", name)
+	default:
+		return nil, fmt.Errorf("unknown store %q, must be one of: memory", name)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResolvePort(tt *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		flagPort int
+		flagSet  bool
+		env      string
+		want     int
+		wantErr  bool
+	}{
+		{name: "default", flagPort: 8080, want: 8080},
+		{name: "env", flagPort: 8080, env: "9090", want: 9090},
+		{name: "flag takes precedence", flagPort: 7070, flagSet: true, env: "9090", want: 7070},
+		{name: "invalid env", flagPort: 8080, env: "http", wantErr: true},
+		{name: "out of range env", flagPort: 8080, env: "70000", wantErr: true},
+		{name: "zero env", flagPort: 8080, env: "0", wantErr: true},
+		{name: "invalid flag", flagPort: -1, flagSet: true, wantErr: true},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := resolvePort(tc.flagPort, tc.flagSet, tc.env)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("resolve port error does not match, got %v, want error %v", err, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Fatalf("port does not match, got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewStore(tt *testing.T) {
+	for _, tc := range []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "memory"},
+		{spec: "memory:receipts.db", wantErr: true},
+		{spec: "sqlite:receipts.db", wantErr: true},
+		{spec: "postgres://localhost", wantErr: true},
+		{spec: "", wantErr: true},
+	} {
+		tt.Run(tc.spec, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := newStore(tc.spec)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("new store error does not match, got %v, want error %v", err, tc.wantErr)
+			}
+
+			if !tc.wantErr && store == nil {
+				t.Fatalf("new store returned nil store, want store")
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(tt *testing.T) {
+	for _, tc := range []struct {
+		list    string
+		want    string
+		wantErr bool
+	}{
+		{list: "10.0.0.0/8", want: "[10.0.0.0/8]"},
+		{list: "10.0.0.0/8, 192.0.2.1", want: "[10.0.0.0/8 192.0.2.1/32]"},
+		{list: "2001:db8::1", want: "[2001:db8::1/128]"},
+		{list: "proxy.internal", wantErr: true},
+		{list: "10.0.0.0/8,", wantErr: true},
+	} {
+		tt.Run(tc.list, func(t *testing.T) {
+			t.Parallel()
+
+			prefixes, err := parsePrefixes(tc.list)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parse prefixes error does not match, got %v, want error %v", err, tc.wantErr)
+			}
+
+			if got := fmt.Sprint(prefixes); !tc.wantErr && got != tc.want {
+				t.Fatalf("prefixes do not match, got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// blockingStore is a [Store] that blocks creating receipts until released,
+// signaling each blocked create on entered.
+type blockingStore struct {
+	Store
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Create(ctx context.Context, receipt *Receipt) error {
+	s.entered <- struct{}{}
+	<-s.release
+
+	return s.Store.Create(ctx, receipt)
+}
+
+// panicStore is a [Store] that panics creating the first receipt.
+type panicStore struct {
+	Store
+	once sync.Once
+}
+
+func (s *panicStore) Create(ctx context.Context, receipt *Receipt) error {
+	s.once.Do(func() { panic("store failure") })
+
+	return s.Store.Create(ctx, receipt)
+}
+
+func TestMaxConcurrentProcessing(t *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	store := &blockingStore{
+		Store:   NewMemoryStore(),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	api := NewAPI(WithStore(store), WithMaxConcurrentProcessing(1))
+
+	process := func() *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body)))
+		return rw
+	}
+
+	// Saturate the limit with a request blocked in the store.
+	code := make(chan int)
+	go func() { code <- process().Code }()
+	<-store.entered
+
+	// Requests over the limit are rejected, even when concurrent.
+	var over sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		over.Add(1)
+		go func() {
+			defer over.Done()
+
+			rw := process()
+			if rw.Code != http.StatusServiceUnavailable {
+				t.Errorf("saturated process receipt status does not match, got %d, want 503", rw.Code)
+			}
+			if rw.Header().Get("Retry-After") == "" {
+				t.Errorf("saturated process receipt is missing Retry-After header")
+			}
+		}()
+	}
+	over.Wait()
+
+	close(store.release)
+
+	if got := <-code; got != http.StatusOK {
+		t.Fatalf("process receipt status does not match, got %d, want 200", got)
+	}
+
+	// The slot is released once the request completes.
+	go func() { <-store.entered }()
+	if rw := process(); rw.Code != http.StatusOK {
+		t.Fatalf("process receipt status after release does not match, got %d, want 200", rw.Code)
+	}
+}
+
+func TestMaxConcurrentProcessingPanic(t *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	api := NewAPI(
+		WithStore(&panicStore{Store: NewMemoryStore()}),
+		WithMaxConcurrentProcessing(1),
+		WithErrorLog(log.New(&bytes.Buffer{}, "", 0)),
+	)
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body)))
+
+		if rw.Code != want {
+			t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, want)
+		}
+	}
+}
+
+func TestMaxConcurrentProcessingInvalid(tt *testing.T) {
+	for _, n := range []int{0, -1} {
+		tt.Run(fmt.Sprint(n), func(t *testing.T) {
+			defer func() {
+				if v := recover(); v == nil {
+					t.Fatalf("WithMaxConcurrentProcessing(%d) did not panic, want panic", n)
+				}
+			}()
+
+			WithMaxConcurrentProcessing(n)
+		})
+	}
+}
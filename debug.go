@@ -0,0 +1,82 @@
+package fetch
+
+import (
+	"maps"
+	"net/http"
+	"time"
+)
+
+// DebugError is the last error produced by an endpoint, as returned from the
+// [DebugErrors] endpoint.
+type DebugError struct {
+	// Status is the HTTP status code of the error response.
+	Status int `json:"status"`
+	// Message is the human-readable error message.
+	Message string `json:"error"`
+	// Time is when the error occurred, in RFC 3339 format.
+	Time string `json:"time"`
+}
+
+// DebugErrorsResponse is the response body that is returned from the
+// [DebugErrors] endpoint.
+type DebugErrorsResponse struct {
+	// Errors are the last errors produced by each endpoint, keyed by request
+	// method and endpoint pattern, e.g. "POST /receipts/process".
+	Errors map[string]DebugError `json:"errors"`
+}
+
+// DebugErrors is an [http.HandlerFunc] that returns the last error produced by
+// each endpoint of the API. The endpoint is only available when the API is
+// configured with [WithDebug].
+func (api *API) DebugErrors(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	api.errorsMu.Lock()
+	lastErrors := maps.Clone(api.lastErrors)
+	api.errorsMu.Unlock()
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&DebugErrorsResponse{
+		Errors: lastErrors,
+	})
+}
+
+// recordError records the error as the last error of the endpoint handling
+// the request, if the debug endpoints are enabled. Errors are keyed by the
+// route pattern matched by the request rather than its path, and by
+// normalized method, so that clients cannot grow the recorded errors without
+// bound.
+func (api *API) recordError(req *http.Request, status int, msg string) {
+	if !api.debug {
+		return
+	}
+
+	// Requests rejected by middleware have not yet been routed.
+	pattern, _ := req.Context().Value(patternKey{}).(string)
+	if pattern == "" {
+		_, pattern = api.mux.Handler(req)
+	}
+
+	api.errorsMu.Lock()
+	defer api.errorsMu.Unlock()
+
+	api.lastErrors[normalizeMethod(req.Method)+" "+pattern] = DebugError{
+		Status:  status,
+		Message: msg,
+		Time:    api.now().UTC().Format(time.RFC3339),
+	}
+}
+
+// normalizeMethod returns the request method, or "OTHER" for methods not
+// defined by RFC 9110 and RFC 5789.
+func normalizeMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE":
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,89 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugErrors(t *testing.T) {
+	api := NewAPI(WithDebug())
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/receipts/process", strings.NewReader(`{`)),
+		httptest.NewRequest("GET", "/receipts/unknown/points", nil),
+		httptest.NewRequest("GET", "/no/such/endpoint", nil),
+		httptest.NewRequest("BREW", "/receipts/process", nil),
+	} {
+		api.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/debug/errors", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get debug errors, got %d status code, want 200", rw.Code)
+	}
+
+	var got DebugErrorsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse debug errors response, got %v, want no error", err)
+	}
+
+	for key, status := range map[string]int{
+		"POST /receipts/process":    http.StatusBadRequest,
+		"GET /receipts/{id}/points": http.StatusNotFound,
+		"GET /":                     http.StatusNotFound,
+		"OTHER /receipts/process":   http.StatusMethodNotAllowed,
+	} {
+		if e, ok := got.Errors[key]; !ok || e.Status != status || e.Message == "" {
+			t.Fatalf("last error of %q does not match, got %+v, want %d status with message", key, e, status)
+		}
+	}
+
+	if len(got.Errors) != 4 {
+		t.Fatalf("number of errors does not match, got %d, want 4", len(got.Errors))
+	}
+}
+
+func TestDebugErrorsDisabled(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/debug/errors", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("disabled debug errors status does not match, got %d, want 404", rw.Code)
+	}
+
+	if len(api.lastErrors) != 0 {
+		t.Fatalf("errors recorded with debug disabled, got %v, want none", api.lastErrors)
+	}
+}
+
+func TestError(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	api.Error(rw, http.StatusNotFound, "no receipt with ID %q exists", "unknown")
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("error status does not match, got %d, want 404", rw.Code)
+	}
+
+	var got Error
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse error response, got %v, want no error", err)
+	}
+
+	want := Error{Message: `no receipt with ID "unknown" exists`, Code: "not_found"}
+	if got != want {
+		t.Fatalf("error response does not match, got %+v, want %+v", got, want)
+	}
+}
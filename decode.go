@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxDecodeSize is the maximum size of a JSON request body decoded by the
+// API.
+const maxDecodeSize = 1 << 20
+
+// WithRejectDuplicateKeys configures the API to reject request bodies with
+// duplicate keys in a JSON object, e.g. two `total` fields, with `400 Bad
+// Request` rather than silently using the last value. This requires an
+// additional pass over each request body so is disabled by default.
+func WithRejectDuplicateKeys() Option {
+	return func(api *API) {
+		api.rejectDuplicateKeys = true
+	}
+}
+
+// decode decodes the first JSON value from r into v, checking for duplicate
+// keys if configured. Bodies larger than maxDecodeSize are rejected.
+func (api *API) decode(r io.Reader, v any) error {
+	r = http.MaxBytesReader(nil, io.NopCloser(r), maxDecodeSize)
+
+	if !api.rejectDuplicateKeys {
+		return json.NewDecoder(r).Decode(v)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDuplicateKeys(b); err != nil {
+		return err
+	}
+
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// checkDuplicateKeys returns an error if the first JSON value in b contains an
+// object with duplicate keys. Keys are compared ignoring case, as
+// [encoding/json] matches keys to fields ignoring case, so that e.g. `total`
+// and `Total` are duplicates. Syntax errors are ignored, leaving them to be
+// reported when decoding the value.
+func checkDuplicateKeys(b []byte) error {
+	// frame is an object or array being scanned. For objects, keys are the
+	// keys seen so far and key is true if the next token is a key.
+	type frame struct {
+		object bool
+		keys   map[string]bool
+		key    bool
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var stack []*frame
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].key {
+			top := stack[n-1]
+
+			if key, ok := tok.(string); ok {
+				folded := strings.ToLower(key)
+				if top.keys[folded] {
+					return fmt.Errorf("duplicate key %q in JSON object", key)
+				}
+				top.keys[folded] = true
+				top.key = false
+				continue
+			}
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &frame{object: true, keys: make(map[string]bool), key: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &frame{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// A value is complete, so the next token of the enclosing object is
+		// a key. The scan is done once the first value is complete.
+		if len(stack) == 0 {
+			return nil
+		}
+		if top := stack[len(stack)-1]; top.object {
+			top.key = true
+		}
+	}
+}
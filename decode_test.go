@@ -0,0 +1,93 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckDuplicateKeys(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "no duplicates", body: `{"total": "1.00", "items": [{"price": "1.00"}, {"price": "1.00"}]}`},
+		{name: "duplicate key", body: `{"total": "1.00", "total": "100.00"}`, wantErr: true},
+		{name: "mixed case duplicate key", body: `{"total": "1.00", "Total": "99.00"}`, wantErr: true},
+		{name: "nested duplicate key", body: `{"items": [{"price": "1.00", "price": "2.00"}]}`, wantErr: true},
+		{name: "same key in sibling objects", body: `{"a": {"total": 1}, "b": {"total": 2}}`},
+		{name: "key matching value", body: `{"total": "total"}`},
+		{name: "array of strings", body: `["total", "total"]`},
+		{name: "trailing values ignored", body: `{"total": 1} {"total": 1, "total": 2}`},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkDuplicateKeys([]byte(tc.body))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("check duplicate keys error does not match, got %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRejectDuplicateKeys(tt *testing.T) {
+	body := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00", "total": "100.00"}`
+
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		status int
+	}{
+		{name: "default", status: http.StatusOK},
+		{name: "strict", opts: []Option{WithRejectDuplicateKeys()}, status: http.StatusBadRequest},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			if tc.status == http.StatusBadRequest && !strings.Contains(rw.Body.String(), `duplicate key \"total\"`) {
+				t.Fatalf("error does not match, got %q, want duplicate key error", rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestDecodeMaxSize(tt *testing.T) {
+	body := `{"retailer": "` + strings.Repeat("a", maxDecodeSize) + `"}`
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "strict", opts: []Option{WithRejectDuplicateKeys()}},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			var prreq ProcessReceiptRequest
+			err := api.decode(strings.NewReader(body), &prreq)
+
+			var maxErr *http.MaxBytesError
+			if !errors.As(err, &maxErr) {
+				t.Fatalf("decode error does not match, got %v, want %T", err, maxErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"net/http"
+)
+
+// DiffPointsRequest is the request body that is submitted to the [DiffPoints]
+// endpoint.
+type DiffPointsRequest struct {
+	// Receipt is the receipt to calculate the points of.
+	Receipt ProcessReceiptRequest `json:"receipt"`
+	// RulesetA is the baseline ruleset to calculate the points under. Rules
+	// omitted from the ruleset keep their [DefaultRuleset] values.
+	RulesetA *Ruleset `json:"rulesetA"`
+	// RulesetB is the ruleset to compare against the baseline. Rules omitted
+	// from the ruleset keep their [DefaultRuleset] values.
+	RulesetB *Ruleset `json:"rulesetB"`
+}
+
+// DiffPointsResponse is the response body that is returned from the
+// [DiffPoints] endpoint.
+type DiffPointsResponse struct {
+	// PointsA are the points of the receipt under ruleset A.
+	PointsA int `json:"pointsA"`
+	// PointsB are the points of the receipt under ruleset B.
+	PointsB int `json:"pointsB"`
+	// Delta is the change in points from ruleset A to ruleset B.
+	Delta int `json:"delta"`
+}
+
+// DiffPoints is an [http.HandlerFunc] that calculates the point value of a
+// receipt under two rulesets without storing the receipt, allowing the impact
+// of a change to the rules, e.g. a new campaign, to be quantified.
+//
+// A receipt rejected by either ruleset, e.g. a receipt with no items under a
+// ruleset which rejects empty receipts, is reported as invalid along with the
+// ruleset which rejected it.
+func (api *API) DiffPoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	dpreq := DiffPointsRequest{
+		RulesetA: DefaultRuleset(),
+		RulesetB: DefaultRuleset(),
+	}
+	if err := api.decode(req.Body, &dpreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse diff points request, %v", err)
+		return
+	}
+
+	if dpreq.RulesetA == nil {
+		dpreq.RulesetA = DefaultRuleset()
+	}
+	if dpreq.RulesetB == nil {
+		dpreq.RulesetB = DefaultRuleset()
+	}
+
+	rulesets := []struct {
+		name    string
+		ruleset *Ruleset
+	}{
+		{name: "rulesetA", ruleset: dpreq.RulesetA},
+		{name: "rulesetB", ruleset: dpreq.RulesetB},
+	}
+
+	for _, rs := range rulesets {
+		if err := rs.ruleset.Validate(); err != nil {
+			api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid %s, %v", rs.name, err)
+			return
+		}
+	}
+
+	receipt, _, err := api.parseReceipt(&dpreq.Receipt)
+	if err != nil {
+		api.WriteError(rw, req, receiptErrorStatus(err), "invalid diff points request, %v", err)
+		return
+	}
+
+	for _, rs := range rulesets {
+		if err := rs.ruleset.accept(receipt); err != nil {
+			api.WriteError(rw, req, receiptErrorStatus(err), "invalid diff points request, receipt rejected by %s, %v", rs.name, err)
+			return
+		}
+	}
+
+	resp := DiffPointsResponse{
+		PointsA: dpreq.RulesetA.CalculatePoints(receipt),
+		PointsB: dpreq.RulesetB.CalculatePoints(receipt),
+	}
+	resp.Delta = resp.PointsB - resp.PointsA
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&resp)
+}
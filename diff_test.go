@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffPoints(tt *testing.T) {
+	api := NewAPI()
+
+	// Diffs must not store receipts, checked once all subtests complete.
+	tt.Cleanup(func() {
+		if n := countReceipts(tt, api); n != 0 {
+			tt.Errorf("stored receipt count does not match, got %d, want 0", n)
+		}
+	})
+
+	receipt, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	empty := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "items": [], "total": "0.00"}`
+
+	for _, tc := range []struct {
+		name     string
+		receipt  string
+		rulesets string
+		status   int
+		want     DiffPointsResponse
+	}{
+		{
+			name:     "default rulesets",
+			rulesets: `"rulesetA": {}, "rulesetB": {}`,
+			status:   http.StatusOK,
+			want:     DiffPointsResponse{PointsA: 28, PointsB: 28, Delta: 0},
+		},
+		{
+			name:     "doubled item pair points",
+			rulesets: `"rulesetA": {}, "rulesetB": {"itemPairPoints": 10}`,
+			status:   http.StatusOK,
+			want:     DiffPointsResponse{PointsA: 28, PointsB: 38, Delta: 10},
+		},
+		{
+			name:     "reduced item pair points",
+			rulesets: `"rulesetA": {"itemPairPoints": 10}, "rulesetB": {"itemPairPoints": 0}`,
+			status:   http.StatusOK,
+			want:     DiffPointsResponse{PointsA: 38, PointsB: 18, Delta: -20},
+		},
+		{
+			name:     "omitted rulesets",
+			rulesets: `"rulesetB": {"leftoverItemPoints": 3}`,
+			status:   http.StatusOK,
+			want:     DiffPointsResponse{PointsA: 28, PointsB: 31, Delta: 3},
+		},
+		{
+			name:     "invalid ruleset",
+			rulesets: `"rulesetA": {}, "rulesetB": {"itemPairPoints": -1}`,
+			status:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "empty receipt scored by both rulesets",
+			receipt:  empty,
+			rulesets: `"rulesetA": {}, "rulesetB": {"emptyReceipts": "zero"}`,
+			status:   http.StatusOK,
+			want:     DiffPointsResponse{PointsA: 87, PointsB: 0, Delta: -87},
+		},
+		{
+			name:     "empty receipt rejected by ruleset",
+			receipt:  empty,
+			rulesets: `"rulesetA": {}, "rulesetB": {"emptyReceipts": "reject"}`,
+			status:   http.StatusUnprocessableEntity,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.receipt == "" {
+				tc.receipt = string(receipt)
+			}
+
+			body := `{"receipt": ` + tc.receipt + `, ` + tc.rulesets + `}`
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/diff", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("diff points status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var got DiffPointsResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse diff points response, got %v, want no error", err)
+			}
+
+			if got != tc.want {
+				t.Fatalf("diff points does not match, got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+}
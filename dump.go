@@ -0,0 +1,211 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"time"
+)
+
+// dumpVersion is the version of the dump format written by [API.Export].
+const dumpVersion = 1
+
+// dump is the portable JSON representation of all stored receipts written by
+// [API.Export] and read by [API.Import].
+type dump struct {
+	// Version is the version of the dump format.
+	Version int `json:"version"`
+	// Receipts are the stored receipts, ordered by ID.
+	Receipts []dumpReceipt `json:"receipts"`
+}
+
+// dumpReceipt is a single receipt in a [dump], including its points history
+// and any persisted breakdown and raw request. Amounts are represented as
+// cents so that they round-trip exactly.
+type dumpReceipt struct {
+	ID              string            `json:"id"`
+	Retailer        string            `json:"retailer"`
+	Purchased       time.Time         `json:"purchased"`
+	Items           []dumpItem        `json:"items"`
+	Total           int               `json:"total"`
+	DiscountPercent int               `json:"discountPercent,omitempty"`
+	DiscountAmount  int               `json:"discountAmount,omitempty"`
+	Points          int               `json:"points"`
+	Scored          bool              `json:"scored"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	UserID          string            `json:"userId,omitempty"`
+	NewCustomer     bool              `json:"newCustomer,omitempty"`
+	ContentHash     string            `json:"contentHash,omitempty"`
+	Deleted         bool              `json:"deleted,omitempty"`
+	DeletedAt       time.Time         `json:"deletedAt"`
+	PointsExpireAt  time.Time         `json:"pointsExpireAt"`
+	PointsHistory   []dumpPointsEvent `json:"pointsHistory,omitempty"`
+	Breakdown       []RulePoints      `json:"breakdown,omitempty"`
+	// RawRequest is encoded as base64, since the raw request body may not
+	// be valid JSON.
+	RawRequest          []byte `json:"rawRequest,omitempty"`
+	RawRequestTruncated bool   `json:"rawRequestTruncated,omitempty"`
+}
+
+// dumpPointsEvent is a single change in the points history of a
+// [dumpReceipt].
+type dumpPointsEvent struct {
+	Time      time.Time `json:"time"`
+	OldPoints int       `json:"oldPoints"`
+	NewPoints int       `json:"newPoints"`
+	Reason    string    `json:"reason"`
+}
+
+// dumpItem is a single line item of a [dumpReceipt].
+type dumpItem struct {
+	Description string `json:"description"`
+	Price       int    `json:"price"`
+}
+
+// Export writes all stored receipts, including soft-deleted receipts, to w as
+// a versioned JSON dump which may be read by [API.Import], e.g. to migrate
+// receipts between stores. The dump preserves the full state of the receipts
+// exactly, including their IDs, points, and points history.
+func (api *API) Export(w io.Writer) error {
+	receipts, err := api.store.List(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list receipts, %w", err)
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].ID < receipts[j].ID
+	})
+
+	d := dump{
+		Version:  dumpVersion,
+		Receipts: make([]dumpReceipt, 0, len(receipts)),
+	}
+
+	for _, receipt := range receipts {
+		dr := dumpReceipt{
+			ID:              receipt.ID,
+			Retailer:        receipt.Retailer,
+			Purchased:       receipt.Purchased.UTC(),
+			Items:           make([]dumpItem, 0, len(receipt.Items)),
+			Total:           receipt.Total,
+			DiscountPercent: receipt.Discount.Percent,
+			DiscountAmount:  receipt.Discount.Amount,
+			Points:          receipt.Points,
+			Scored:          receipt.Scored,
+			Metadata:        receipt.Metadata,
+			UserID:          receipt.UserID,
+			NewCustomer:     receipt.NewCustomer,
+			ContentHash:     receipt.ContentHash,
+			Deleted:         receipt.Deleted,
+			DeletedAt:       receipt.DeletedAt.UTC(),
+			PointsExpireAt:  receipt.PointsExpireAt.UTC(),
+			Breakdown:       receipt.Breakdown,
+			RawRequest:      receipt.RawRequest,
+
+			RawRequestTruncated: receipt.RawRequestTruncated,
+		}
+
+		for _, item := range receipt.Items {
+			dr.Items = append(dr.Items, dumpItem(item))
+		}
+		for _, event := range receipt.PointsHistory {
+			event.Time = event.Time.UTC()
+			dr.PointsHistory = append(dr.PointsHistory, dumpPointsEvent(event))
+		}
+
+		d.Receipts = append(d.Receipts, dr)
+	}
+
+	return json.NewEncoder(w).Encode(&d)
+}
+
+// Import reads a JSON dump written by [API.Export] from r and stores its
+// receipts as exported, without recalculating the points. Imported receipts
+// are not awarded the new customer bonus. Scored receipts exported without a
+// points history are recorded as imported in their history.
+//
+// The dump is read in full before any receipt is stored, so a malformed dump,
+// or one with receipt IDs which do not match the ID pattern of the API, see
+// [WithIDPattern], stores nothing. Import fails if a receipt with the ID of an imported
+// receipt already exists, leaving the receipts stored before it in place.
+func (api *API) Import(r io.Reader) error {
+	var d dump
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return fmt.Errorf("failed to parse dump, %w", err)
+	}
+	if d.Version != dumpVersion {
+		return fmt.Errorf("unsupported dump version %d, must be %d", d.Version, dumpVersion)
+	}
+	for _, dr := range d.Receipts {
+		if !api.idPattern.MatchString(dr.ID) {
+			return fmt.Errorf("invalid receipt %q, ID does not match the ID pattern", dr.ID)
+		}
+	}
+
+	ctx := context.Background()
+
+	for _, dr := range d.Receipts {
+		receipt := &Receipt{
+			ID:             dr.ID,
+			Retailer:       dr.Retailer,
+			Points:         dr.Points,
+			Scored:         dr.Scored,
+			Purchased:      dr.Purchased.UTC(),
+			Total:          dr.Total,
+			Discount:       Discount{Percent: dr.DiscountPercent, Amount: dr.DiscountAmount},
+			Metadata:       maps.Clone(dr.Metadata),
+			UserID:         dr.UserID,
+			NewCustomer:    dr.NewCustomer,
+			ContentHash:    dr.ContentHash,
+			Deleted:        dr.Deleted,
+			DeletedAt:      dr.DeletedAt,
+			PointsExpireAt: dr.PointsExpireAt,
+			Breakdown:      dr.Breakdown,
+			RawRequest:     dr.RawRequest,
+
+			RawRequestTruncated: dr.RawRequestTruncated,
+		}
+
+		for _, item := range dr.Items {
+			receipt.Items = append(receipt.Items, ReceiptItem(item))
+		}
+		for _, event := range dr.PointsHistory {
+			receipt.PointsHistory = append(receipt.PointsHistory, PointsEvent(event))
+		}
+
+		if len(receipt.PointsHistory) == 0 && dr.Scored {
+			receipt.PointsHistory = []PointsEvent{{Time: api.now(), NewPoints: dr.Points, Reason: "imported"}}
+		}
+
+		if err := api.storePreserved(ctx, receipt); errors.Is(err, ErrExists) {
+			return fmt.Errorf("failed to import receipt %q, receipt already exists", dr.ID)
+		} else if err != nil {
+			return fmt.Errorf("failed to import receipt %q, %w", dr.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// storePreserved stores the receipt with its ID and points as given, recording
+// its content hash, or returns [ErrExists] if a receipt with the same ID
+// already exists.
+func (api *API) storePreserved(ctx context.Context, receipt *Receipt) error {
+	return api.locked(ctx, func(ctx context.Context) error {
+		if err := api.loadIndex(ctx); err != nil {
+			return err
+		}
+
+		if err := api.store.Create(ctx, receipt); err != nil {
+			return err
+		}
+
+		api.index(receipt)
+
+		return nil
+	})
+}
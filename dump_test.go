@@ -0,0 +1,183 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	src := NewAPI()
+
+	var ids []string
+	for _, path := range []string{
+		"testdata/simple-receipt.json",
+		"testdata/morning-receipt.json",
+		"testdata/readme-target-receipt.json",
+		"testdata/readme-corner-market-receipt.json",
+	} {
+		ids = append(ids, processReceipt(t, src, path))
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("failed to export receipts, got %v, want no error", err)
+	}
+
+	// Import under a different ruleset to verify that points are preserved
+	// rather than recalculated.
+	rs := DefaultRuleset()
+	rs.ItemPairPoints = 100
+
+	dst := NewAPI(WithRuleset(rs))
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("failed to import receipts, got %v, want no error", err)
+	}
+
+	if got, want := countReceipts(t, dst), len(ids); got != want {
+		t.Fatalf("imported receipt count does not match, got %d, want %d", got, want)
+	}
+
+	for _, id := range ids {
+		want, _ := src.Receipt(id)
+
+		got, ok := dst.Receipt(id)
+		if !ok {
+			t.Fatalf("imported receipt %q does not exist", id)
+		}
+
+		if got.Points != want.Points || !got.Scored {
+			t.Fatalf("imported receipt %q points do not match, got %d, want %d", id, got.Points, want.Points)
+		}
+		if !got.Purchased.Equal(want.Purchased) || got.Total != want.Total || len(got.Items) != len(want.Items) {
+			t.Fatalf("imported receipt %q does not match, got %+v, want %+v", id, got, want)
+		}
+		if points := getPoints(t, dst, id); points != want.Points {
+			t.Fatalf("imported receipt %q fetched points do not match, got %d, want %d", id, points, want.Points)
+		}
+	}
+
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Fatalf("reimport error does not match, got nil, want error for existing receipts")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	now := time.Date(2022, 2, 1, 12, 0, 0, 0, time.UTC)
+	opts := []Option{
+		WithClock(func() time.Time { return now }),
+		WithPersistBreakdowns(),
+		WithRawRequests(1024),
+		WithPointsTTL(30 * 24 * time.Hour),
+	}
+
+	src := NewAPI(opts...)
+
+	adjusted := processReceipt(t, src, "testdata/simple-receipt.json")
+	deleted := processReceipt(t, src, "testdata/morning-receipt.json")
+	processReceipt(t, src, "testdata/readme-target-receipt.json")
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("PUT", "/receipts/"+adjusted+"/points", strings.NewReader(`{"points": 40, "reason": "customer satisfaction"}`)),
+		httptest.NewRequest("DELETE", "/receipts/"+deleted, nil),
+	} {
+		rw := httptest.NewRecorder()
+
+		src.ServeHTTP(rw, req)
+
+		if rw.Code >= 300 {
+			t.Fatalf("failed to %s receipt, got %d status code, want success", req.Method, rw.Code)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("failed to export receipts, got %v, want no error", err)
+	}
+
+	now = now.Add(time.Hour)
+
+	dst := NewAPI(opts...)
+	if err := dst.Import(&buf); err != nil {
+		t.Fatalf("failed to import receipts, got %v, want no error", err)
+	}
+
+	want, err := src.store.List(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list exported receipts, got %v, want no error", err)
+	}
+
+	for _, receipt := range want {
+		got, ok := dst.Receipt(receipt.ID)
+		if !ok {
+			t.Fatalf("imported receipt %q does not exist", receipt.ID)
+		}
+
+		if !reflect.DeepEqual(got, receipt) {
+			t.Fatalf("imported receipt %q does not match, got %+v, want %+v", receipt.ID, got, receipt)
+		}
+	}
+}
+
+func TestExportImportPreloaded(t *testing.T) {
+	a := &Receipt{ID: "7fb1377b-b223-49d9-a31a-5a02701dd310", Retailer: "Target", Total: 100, Points: 28}
+	b := &Receipt{ID: "d4b6e5f2-3c1a-4b8e-9f0d-2a7c6e1b3d5f", Retailer: "Walgreens", Total: 265, Points: 109}
+
+	clock := WithClock(func() time.Time { return time.Date(2022, 2, 1, 12, 0, 0, 0, time.UTC) })
+
+	src, err := NewAPIWithReceipts([]*Receipt{a, b}, clock)
+	if err != nil {
+		t.Fatalf("failed to create API, got %v, want no error", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("failed to export receipts, got %v, want no error", err)
+	}
+
+	dst := NewAPI(clock)
+	if err := dst.Import(&buf); err != nil {
+		t.Fatalf("failed to import receipts, got %v, want no error", err)
+	}
+
+	for _, id := range []string{a.ID, b.ID} {
+		want, _ := src.Receipt(id)
+
+		got, ok := dst.Receipt(id)
+		if !ok {
+			t.Fatalf("imported receipt %q does not exist", id)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("imported receipt %q does not match, got %+v, want %+v", id, got, want)
+		}
+		if points := getPoints(t, dst, id); points != want.Points {
+			t.Fatalf("imported receipt %q fetched points do not match, got %d, want %d", id, points, want.Points)
+		}
+	}
+}
+
+func TestImportInvalidDump(tt *testing.T) {
+	for _, tc := range []struct {
+		name string
+		dump string
+	}{
+		{name: "malformed", dump: `{"version": 1, "receipts": [`},
+		{name: "unsupported version", dump: `{"version": 2, "receipts": []}`},
+		{name: "invalid ID", dump: `{"version": 1, "receipts": [{"id": "../receipts"}]}`},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI()
+			if err := api.Import(strings.NewReader(tc.dump)); err == nil {
+				t.Fatalf("import error does not match, got nil, want error")
+			}
+		})
+	}
+}
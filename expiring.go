@@ -0,0 +1,92 @@
+package fetch
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithPointsTTL configures the API to expire the points of processed receipts
+// after ttl, recording the expiry on the receipt when it is processed. See
+// [ExpiringPoints]. By default points never expire.
+func WithPointsTTL(ttl time.Duration) Option {
+	return func(api *API) {
+		api.pointsTTL = ttl
+	}
+}
+
+// ExpiringPoints is an [http.HandlerFunc] that returns the receipts whose
+// points expire within the duration of the `within` query parameter from now,
+// e.g. for loyalty reminders, as a JSON array of [ReceiptResponse] ordered by
+// expiry. The duration is either a number of days, e.g. `30d`, or a Go
+// duration, e.g. `12h`. Receipts whose points have already expired or never
+// expire and soft-deleted receipts are excluded.
+func (api *API) ExpiringPoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	within, err := parseWithin(req.URL.Query().Get("within"))
+	if err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "invalid within query parameter, %v", err)
+		return
+	}
+
+	receipts, err := api.store.List(req.Context())
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to list receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+
+	now := api.now()
+	deadline := now.Add(within)
+
+	receipts = slices.DeleteFunc(receipts, func(receipt *Receipt) bool {
+		expireAt := receipt.PointsExpireAt
+		return receipt.Deleted || expireAt.IsZero() || !expireAt.After(now) || expireAt.After(deadline)
+	})
+
+	slices.SortFunc(receipts, func(a, b *Receipt) int {
+		return cmp.Or(a.PointsExpireAt.Compare(b.PointsExpireAt), strings.Compare(a.ID, b.ID))
+	})
+
+	resp := make([]*ReceiptResponse, 0, len(receipts))
+	for _, receipt := range receipts {
+		resp = append(resp, api.receiptResponseFrom(receipt))
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(resp)
+}
+
+// parseWithin parses a positive duration that is either a whole number of
+// days, e.g. "30d", or a Go duration, e.g. "12h".
+func parseWithin(within string) (time.Duration, error) {
+	if within == "" {
+		return 0, fmt.Errorf("missing duration, e.g. '30d'")
+	}
+
+	var (
+		d   time.Duration
+		err error
+	)
+	if days, ok := strings.CutSuffix(within, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(within)
+	}
+
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("duration %q must be a positive number of days, e.g. '30d', or a duration, e.g. '12h'", within)
+	}
+
+	return d, nil
+}
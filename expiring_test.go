@@ -0,0 +1,129 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpiringPoints(tt *testing.T) {
+	start := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	api := NewAPI(WithClock(func() time.Time { return now }), WithPointsTTL(30*24*time.Hour))
+
+	// The points of the first receipt expire after 30 days, and of the second
+	// after 50 days.
+	first := processReceipt(tt, api, "testdata/simple-receipt.json")
+	now = start.AddDate(0, 0, 20)
+	second := processReceipt(tt, api, "testdata/morning-receipt.json")
+
+	for _, tc := range []struct {
+		name   string
+		day    int
+		within string
+		want   []string
+	}{
+		{name: "within window", day: 25, within: "10d", want: []string{first}},
+		{name: "both within window", day: 25, within: "30d", want: []string{first, second}},
+		{name: "go duration", day: 25, within: "120h", want: []string{first}},
+		{name: "outside window", day: 25, within: "1d", want: []string{}},
+		{name: "expired", day: 31, within: "30d", want: []string{second}},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			now = start.AddDate(0, 0, tc.day)
+
+			if got := expiringPoints(t, api, tc.within); !slices.Equal(got, tc.want) {
+				t.Fatalf("expiring receipts do not match, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpiringPointsAfterUpdate(t *testing.T) {
+	start := time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	api := NewAPI(WithClock(func() time.Time { return now }), WithPointsTTL(30*24*time.Hour))
+
+	id := processReceipt(t, api, "testdata/simple-receipt.json")
+
+	// Updating the receipt keeps the expiry of its points.
+	now = start.AddDate(0, 0, 10)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/receipts/"+id, strings.NewReader(`{"retailer": "Walmart", "purchaseDate": "2022-01-02", "purchaseTime": "13:13", "total": "1.00"}`))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to update receipt, got %d status code, want 200", rw.Code)
+	}
+
+	now = start.AddDate(0, 0, 25)
+
+	if got := expiringPoints(t, api, "10d"); !slices.Equal(got, []string{id}) {
+		t.Fatalf("expiring receipts do not match, got %v, want %v", got, []string{id})
+	}
+}
+
+func TestExpiringPointsNoTTL(t *testing.T) {
+	api := NewAPI()
+
+	processReceipt(t, api, "testdata/simple-receipt.json")
+
+	if got := expiringPoints(t, api, "36500d"); len(got) != 0 {
+		t.Fatalf("expiring receipts do not match, got %v, want none", got)
+	}
+}
+
+func TestExpiringPointsInvalidWithin(tt *testing.T) {
+	api := NewAPI()
+
+	for _, within := range []string{"", "30", "-1d", "0d", "thirty"} {
+		tt.Run(within, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/points/expiring?within="+within, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusBadRequest {
+				t.Fatalf("expiring points status does not match, got %d, want 400", rw.Code)
+			}
+		})
+	}
+}
+
+// expiringPoints returns the IDs of the receipts whose points expire within
+// the duration.
+func expiringPoints(t *testing.T, api *API, within string) []string {
+	t.Helper()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/points/expiring?within="+within, nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get expiring points, got %d status code, want 200", rw.Code)
+	}
+
+	var got []ReceiptResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse expiring points response, got %v, want no error", err)
+	}
+
+	ids := make([]string, 0, len(got))
+	for _, receipt := range got {
+		if receipt.PointsExpireAt == "" {
+			t.Fatalf("expiring receipt %q has no expiry, want expiry", receipt.ID)
+		}
+		ids = append(ids, receipt.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,70 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// receiptResponseFields are the names of the top-level JSON fields of
+// [ReceiptResponse], which may be selected by the `fields` query parameter of
+// the [GetReceipt] endpoint.
+var receiptResponseFields = jsonFields(reflect.TypeFor[ReceiptResponse]())
+
+// jsonFields returns the set of JSON field names of the struct type.
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+
+	return fields
+}
+
+// parseFields parses a comma separated list of field names, e.g. "id,points",
+// returning an error for names not in known. An empty list selects all fields
+// and returns nil.
+func parseFields(list string, known map[string]bool) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields = append(fields, name)
+	}
+
+	return fields, nil
+}
+
+// selectFields returns the JSON object encoding of v with only the given
+// top-level fields, omitting fields which v itself omits, e.g. empty fields
+// tagged omitempty.
+func selectFields(v any, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, name := range fields {
+		if value, ok := all[name]; ok {
+			selected[name] = value
+		}
+	}
+
+	return selected, nil
+}
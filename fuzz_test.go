@@ -0,0 +1,124 @@
+package fetch
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedReceipts returns the receipts in the testdata directory, used to seed
+// the fuzz corpora.
+func seedReceipts(f *testing.F) []ProcessReceiptRequest {
+	f.Helper()
+
+	paths, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		f.Fatalf("failed to list testdata receipts, got %v, want no error", err)
+	}
+
+	var receipts []ProcessReceiptRequest
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatalf("failed to read receipt file, got %v, want no error", err)
+		}
+
+		var prreq ProcessReceiptRequest
+		if err := json.Unmarshal(b, &prreq); err != nil {
+			f.Fatalf("failed to parse receipt file %q, got %v, want no error", path, err)
+		}
+
+		receipts = append(receipts, prreq)
+	}
+
+	return receipts
+}
+
+func FuzzParseAmount(f *testing.F) {
+	for _, prreq := range seedReceipts(f) {
+		f.Add(prreq.Total)
+		for _, item := range prreq.Items {
+			f.Add(item.Price)
+		}
+	}
+	for _, amount := range []string{"5.5", "-1.50", " 15.30 ", "0.00", "1e3.00", "inf"} {
+		f.Add(amount)
+	}
+
+	f.Fuzz(func(t *testing.T, amount string) {
+		cents, err := parseAmount(amount)
+		if err != nil {
+			return
+		}
+
+		formatted := formatAmount(cents)
+		if want := normalizeAmount(amount); formatted != want {
+			t.Fatalf("formatAmount(parseAmount(%q)) does not match, got %q, want %q", amount, formatted, want)
+		}
+
+		reparsed, err := parseAmount(formatted)
+		if err != nil {
+			t.Fatalf("failed to parse formatted amount %q, got %v, want no error", formatted, err)
+		}
+		if reparsed != cents {
+			t.Fatalf("parseAmount(%q) does not match, got %d, want %d", formatted, reparsed, cents)
+		}
+	})
+}
+
+// normalizeAmount returns the amount accepted by [parseAmount] in the form
+// produced by [formatAmount], without leading zeros, with two decimal places,
+// and without the sign of a zero amount.
+func normalizeAmount(amount string) string {
+	amount = strings.TrimSpace(amount)
+
+	negative := strings.HasPrefix(amount, "-")
+	dollars, cents, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+
+	dollars = strings.TrimLeft(dollars, "0")
+	if dollars == "" {
+		dollars = "0"
+	}
+	if len(cents) == 1 {
+		cents += "0"
+	}
+
+	if negative && (dollars != "0" || cents != "00") {
+		return "-" + dollars + "." + cents
+	}
+
+	return dollars + "." + cents
+}
+
+func FuzzParsePurchased(f *testing.F) {
+	for _, prreq := range seedReceipts(f) {
+		f.Add(prreq.PurchaseDate, prreq.PurchaseTime)
+	}
+	for _, seed := range [][2]string{{"2022-01-01", "00:00"}, {"2022-01-01", "23:59"}, {"2022-02-30", "12:00"}, {"2022-01-01", "24:00"}} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, purchaseDate, purchaseTime string) {
+		purchased, err := parsePurchased(DefaultDateLayouts, purchaseDate, purchaseTime)
+		if err != nil {
+			return
+		}
+
+		if purchased.Location() != time.UTC {
+			t.Fatalf("purchased location does not match, got %v, want UTC", purchased.Location())
+		}
+
+		date, err := time.Parse("2006-01-02", purchaseDate)
+		if err != nil {
+			t.Fatalf("failed to parse purchase date %q, got %v, want no error", purchaseDate, err)
+		}
+
+		// The purchase time is within the purchase date.
+		if d := purchased.Sub(date); d < 0 || d >= 24*time.Hour {
+			t.Fatalf("purchased %v is not on purchase date %q", purchased, purchaseDate)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"net/http"
+)
+
+// HealthResponse is the response body that is returned from the [Health]
+// endpoint.
+type HealthResponse struct {
+	// Status is "ok" if the API is healthy, or "unhealthy" if the store is
+	// unreachable.
+	Status string `json:"status"`
+}
+
+// Health is an [http.HandlerFunc] that reports the health of the API,
+// including the connectivity of the store. The endpoint responds with `503
+// Service Unavailable` if the store health check fails.
+func (api *API) Health(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	resp := HealthResponse{Status: "ok"}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err := api.store.HealthCheck(req.Context()); err != nil {
+		api.logger.WarnContext(req.Context(), "store health check failed", "error", err)
+		api.recordError(req, http.StatusServiceUnavailable, "store health check failed")
+
+		resp.Status = "unhealthy"
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	api.encoder(rw, req).Encode(&resp)
+}
@@ -0,0 +1,51 @@
+package fetch
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(tt *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		store  Store
+		status int
+		body   string
+	}{
+		{
+			name:   "healthy",
+			store:  NewMemoryStore(),
+			status: http.StatusOK,
+			body:   "{\"status\":\"ok\"}\n",
+		},
+		{
+			name:   "unhealthy",
+			store:  &errStore{Store: NewMemoryStore(), healthErr: errors.New("connection refused")},
+			status: http.StatusServiceUnavailable,
+			body:   "{\"status\":\"unhealthy\"}\n",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			api := NewAPI(WithStore(tc.store), WithErrorLog(log.New(&buf, "", 0)))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/healthz", nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("status code does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if got := rw.Body.String(); got != tc.body {
+				t.Fatalf("health response does not match, got %q, want %q", got, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// maxImportLineSize is the maximum size of a single receipt in an import
+// stream.
+const maxImportLineSize = 1 << 20
+
+// ImportProgress is the data of the `progress` and `error` server-sent events
+// sent by the [ImportReceipts] endpoint after each receipt in the import
+// stream.
+type ImportProgress struct {
+	// Line is the line number of the receipt in the import stream.
+	Line int `json:"line"`
+	// ID is the unique ID of the receipt, if processed successfully.
+	ID string `json:"id,omitempty"`
+	// Error is the human-readable error message, if processing failed.
+	Error string `json:"error,omitempty"`
+	// Processed is the number of receipts processed successfully so far.
+	Processed int `json:"processed"`
+	// Failed is the number of receipts that failed processing so far.
+	Failed int `json:"failed"`
+}
+
+// ImportSummary is the data of the final `summary` server-sent event sent by
+// the [ImportReceipts] endpoint once the import stream has been consumed.
+type ImportSummary struct {
+	// Processed is the number of receipts processed successfully.
+	Processed int `json:"processed"`
+	// Failed is the number of receipts that failed processing.
+	Failed int `json:"failed"`
+	// Error is the human-readable error message if the import stream could
+	// not be read to completion.
+	Error string `json:"error,omitempty"`
+}
+
+// ImportReceipts is an [http.HandlerFunc] that receives a newline-delimited
+// JSON stream of receipts, each represented as a [ProcessReceiptRequest], and
+// processes them sequentially.
+//
+// Progress is reported as server-sent events as each receipt is processed, a
+// `progress` event for each success and an `error` event for each failure,
+// finishing with a `summary` event. Events are flushed as they are written so
+// that clients receive feedback during large imports.
+func (api *API) ImportReceipts(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	// The import stream is read and written as receipts are processed, so
+	// must not be cut off by the timeouts of the server, and the request body
+	// must remain readable once the response has been written.
+	clearWriteDeadline(rw)
+
+	rc := http.NewResponseController(rw)
+	rc.SetReadDeadline(time.Time{})
+	rc.EnableFullDuplex()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	flusher, _ := rw.(http.Flusher)
+
+	send := func(event string, data any) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, b)
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	var (
+		summary ImportSummary
+		line    int
+	)
+
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(nil, maxImportLineSize)
+
+	for scanner.Scan() {
+		line++
+
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		id, err := api.importReceipt(req, b)
+		if err != nil {
+			summary.Failed++
+			send("error", &ImportProgress{
+				Line:      line,
+				Error:     err.Error(),
+				Processed: summary.Processed,
+				Failed:    summary.Failed,
+			})
+			continue
+		}
+
+		summary.Processed++
+		send("progress", &ImportProgress{
+			Line:      line,
+			ID:        id,
+			Processed: summary.Processed,
+			Failed:    summary.Failed,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		summary.Error = fmt.Sprintf("failed to read import stream after line %d, %v", line, err)
+	}
+
+	send("summary", &summary)
+}
+
+// storeImported stores an imported receipt, which counts as a prior receipt
+// of its user. Imported receipts are not awarded the new customer bonus.
+func (api *API) storeImported(ctx context.Context, receipt *Receipt) error {
+	return api.locked(ctx, func(ctx context.Context) error {
+		if err := api.loadIndex(ctx); err != nil {
+			return err
+		}
+
+		release, err := api.reserveQuota(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := api.createReceipt(ctx, receipt); err != nil {
+			release()
+			return err
+		}
+		api.index(receipt)
+
+		return nil
+	})
+}
+
+// importReceipt processes and stores a single JSON encoded receipt from an
+// import stream, returning the ID of the receipt.
+func (api *API) importReceipt(req *http.Request, b []byte) (string, error) {
+	var prreq ProcessReceiptRequest
+	if api.rejectDuplicateKeys {
+		if err := checkDuplicateKeys(b); err != nil {
+			return "", fmt.Errorf("failed to parse receipt, %w", err)
+		}
+	}
+	if err := json.Unmarshal(b, &prreq); err != nil {
+		return "", fmt.Errorf("failed to parse receipt, %w", err)
+	}
+
+	receipt, _, err := api.receiptFrom(&prreq, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid receipt, %w", err)
+	}
+
+	var quotaErr *quotaExceededError
+	if err := api.storeImported(req.Context(), receipt); errors.As(err, &quotaErr) {
+		return "", err
+	} else if err != nil {
+		api.logger.ErrorContext(req.Context(), "store failure", "receipt", receipt.ID, "error", err)
+		return "", fmt.Errorf("failed to store receipt")
+	}
+
+	return receipt.ID, nil
+}
@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportReceipts(t *testing.T) {
+	api := NewAPI()
+
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	body := strings.Join([]string{
+		`{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`,
+		`{"retailer": "Target", "purchaseDate": "not a date", "purchaseTime": "13:01", "total": "1.00"}`,
+		`{"retailer": "Walgreens", "purchaseDate": "2022-01-02", "purchaseTime": "08:13", "total": "2.65"}`,
+	}, "\n")
+
+	resp, err := http.Post(srv.URL+"/receipts/import", "application/x-ndjson", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to import receipts, got %v, want no error", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("import content type does not match, got %q, want %q", ct, "text/event-stream")
+	}
+
+	var (
+		events   []string
+		progress []ImportProgress
+		summary  ImportSummary
+		event    string
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+			events = append(events, event)
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+
+			var err error
+			if event == "summary" {
+				err = json.Unmarshal(data, &summary)
+			} else {
+				var p ImportProgress
+				err = json.Unmarshal(data, &p)
+				progress = append(progress, p)
+			}
+			if err != nil {
+				t.Fatalf("failed to parse %s event, got %v, want no error", event, err)
+			}
+		}
+	}
+
+	if got, want := strings.Join(events, ","), "progress,error,progress,summary"; got != want {
+		t.Fatalf("import events do not match, got %q, want %q", got, want)
+	}
+
+	if p := progress[1]; p.Line != 2 || p.Error == "" || p.Processed != 1 || p.Failed != 1 {
+		t.Fatalf("import error event does not match, got %+v, want line 2 error with 1 processed and 1 failed", p)
+	}
+
+	if summary.Processed != 2 || summary.Failed != 1 {
+		t.Fatalf("import summary does not match, got %+v, want 2 processed and 1 failed", summary)
+	}
+
+	if n := countReceipts(t, api); n != 2 {
+		t.Fatalf("stored receipt count does not match, got %d, want 2", n)
+	}
+}
+
+func TestImportReceiptsServerTimeouts(t *testing.T) {
+	api := NewAPI()
+
+	// The import stream outlasts the timeouts of the server, which must not
+	// cut it off.
+	srv := httptest.NewUnstartedServer(api)
+	srv.Config.ReadTimeout = 50 * time.Millisecond
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			fmt.Fprintln(pw, `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`)
+		}
+		pw.Close()
+	}()
+
+	resp, err := http.Post(srv.URL+"/receipts/import", "application/x-ndjson", pr)
+	if err != nil {
+		t.Fatalf("failed to import receipts, got %v, want no error", err)
+	}
+	defer resp.Body.Close()
+
+	var summary ImportSummary
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			json.Unmarshal([]byte(data), &summary)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read import events, got %v, want no error", err)
+	}
+
+	if summary.Processed != 3 || summary.Failed != 0 || summary.Error != "" {
+		t.Fatalf("import summary does not match, got %+v, want 3 processed", summary)
+	}
+}
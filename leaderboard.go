@@ -0,0 +1,93 @@
+package fetch
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+const (
+	// defaultLeaderboardLimit is the number of receipts returned from the
+	// [Leaderboard] endpoint if no limit is given.
+	defaultLeaderboardLimit = 10
+	// maxLeaderboardLimit is the maximum number of receipts returned from the
+	// [Leaderboard] endpoint.
+	maxLeaderboardLimit = 100
+)
+
+// LeaderboardResponse is the response body that is returned from the
+// [Leaderboard] endpoint.
+type LeaderboardResponse struct {
+	// Receipts are the top receipts by points, in descending order of points.
+	Receipts []LeaderboardEntry `json:"receipts"`
+}
+
+// LeaderboardEntry is a single receipt in [LeaderboardResponse].
+type LeaderboardEntry struct {
+	// ID is the unique ID of the receipt.
+	ID string `json:"id"`
+	// Retailer is the name of the seller where the purchase was made.
+	Retailer string `json:"retailer"`
+	// Points are the number of Fetch rewards points assigned to the receipt.
+	Points int `json:"points"`
+}
+
+// Leaderboard is an [http.HandlerFunc] that returns the top receipts by
+// points in descending order, ties broken by ID. The number of receipts is
+// given by the `limit` query parameter, defaulting to 10 and at most 100.
+// Soft-deleted receipts are excluded.
+//
+// The leaderboard is computed from a snapshot of the store on each request,
+// which is sufficient for the in-memory store but should be replaced with a
+// sorted index maintained on save for large stores.
+func (api *API) Leaderboard(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	limit := defaultLeaderboardLimit
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLeaderboardLimit {
+			api.WriteError(rw, req, http.StatusBadRequest, "invalid limit %q, must be between 1 and %d", v, maxLeaderboardLimit)
+			return
+		}
+		limit = n
+	}
+
+	receipts, err := api.store.List(req.Context())
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to list receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(receipts))
+	for _, receipt := range receipts {
+		if receipt.Deleted {
+			continue
+		}
+
+		entries = append(entries, LeaderboardEntry{
+			ID:       receipt.ID,
+			Retailer: receipt.Retailer,
+			Points:   api.points(receipt),
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b LeaderboardEntry) int {
+		if c := cmp.Compare(b.Points, a.Points); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	resp := LeaderboardResponse{
+		Receipts: entries[:min(limit, len(entries))],
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&resp)
+}
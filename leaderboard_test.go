@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(tt *testing.T) {
+	api := NewAPI()
+
+	for id, points := range map[string]int{
+		"a": 10,
+		"b": 50,
+		"c": 30,
+		"d": 50,
+		"e": 5,
+	} {
+		receipt := &Receipt{ID: id, Retailer: "Target " + id}
+		receipt.SetPoints(points, "initial calculation", time.Now())
+
+		if err := api.store.Save(context.Background(), receipt); err != nil {
+			tt.Fatalf("failed to save receipt, got %v, want no error", err)
+		}
+	}
+
+	deleted := &Receipt{ID: "f", Retailer: "Target f", Deleted: true}
+	deleted.SetPoints(100, "initial calculation", time.Now())
+
+	if err := api.store.Save(context.Background(), deleted); err != nil {
+		tt.Fatalf("failed to save receipt, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "default limit",
+			query: "",
+			want:  []string{"b", "d", "c", "a", "e"},
+		},
+		{
+			name:  "top three with tie",
+			query: "?limit=3",
+			want:  []string{"b", "d", "c"},
+		},
+		{
+			name:  "top one",
+			query: "?limit=1",
+			want:  []string{"b"},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/leaderboard"+tc.query, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to get leaderboard, got %d status code, want 200", rw.Code)
+			}
+
+			var resp LeaderboardResponse
+			if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to parse leaderboard response, got %v, want no error", err)
+			}
+
+			var got []string
+			for _, entry := range resp.Receipts {
+				got = append(got, entry.ID)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("leaderboard does not match, got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	for _, limit := range []string{"0", "101", "ten"} {
+		tt.Run(fmt.Sprintf("invalid limit %s", limit), func(t *testing.T) {
+			t.Parallel()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/leaderboard?limit="+limit, nil)
+
+			api.ServeHTTP(rw, req)
+
+			if got, want := rw.Code, http.StatusBadRequest; got != want {
+				t.Fatalf("status code does not match, got %d, want %d", got, want)
+			}
+		})
+	}
+}
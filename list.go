@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"net/http"
+	"time"
+)
+
+// ReceiptResponse is the representation of a stored receipt that is returned
+// from the [GetReceipt] and [ListReceipts] endpoints.
+type ReceiptResponse struct {
+	// ID is the unique ID of the receipt.
+	ID string `json:"id"`
+	// Retailer is the name of the seller where the purchase was made.
+	Retailer string `json:"retailer"`
+	// PurchaseDate is the date that the purchase was made, e.g "2006-01-02".
+	PurchaseDate string `json:"purchaseDate"`
+	// PurchaseTime is the time that the purchase was made in 24-hour time
+	// format, e.g. "14:30".
+	PurchaseTime string `json:"purchaseTime"`
+	// Items are the individual line items on the receipt.
+	Items []ProcessReceiptItem `json:"items"`
+	// Total is the sum of all costs of line items on the receipt, represented
+	// as a string monetary value, e.g. "15.30".
+	Total string `json:"total"`
+	// Discount is the discount applied to the total, if any, e.g. "10%" or
+	// "1.50".
+	Discount string `json:"discount,omitempty"`
+	// Points are the number of Fetch rewards points assigned to the receipt.
+	Points int `json:"points"`
+	// Scored is true if points have been assigned to the receipt.
+	Scored bool `json:"scored"`
+	// PointsExpireAt is when the points of the receipt expire in RFC 3339
+	// format, if they expire.
+	PointsExpireAt string `json:"pointsExpireAt,omitempty"`
+	// Metadata is arbitrary client data attached to the receipt.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// UserID is the ID of the user that submitted the receipt, if any.
+	UserID string `json:"userId,omitempty"`
+	// Deleted is true if the receipt has been soft-deleted.
+	Deleted bool `json:"deleted,omitempty"`
+	// Explanation is the breakdown of the points by rule, included by the
+	// [GetReceipt] endpoint if requested.
+	Explanation *PointsExplanation `json:"explanation,omitempty"`
+}
+
+// PointsExplanation is the breakdown of the points of a receipt by rule.
+type PointsExplanation struct {
+	// Rules are the points awarded to the receipt by each rule under the
+	// current ruleset.
+	Rules []RulePoints `json:"rules"`
+	// RuleTotal is the sum of the points awarded by the rules.
+	RuleTotal int `json:"ruleTotal"`
+	// Adjusted is true if the points assigned to the receipt differ from the
+	// rule total, e.g. due to a manual adjustment or a change to the ruleset
+	// since the receipt was processed.
+	Adjusted bool `json:"adjusted"`
+}
+
+// receiptResponseFrom creates a new [ReceiptResponse] from the [Receipt], with
+// the points as returned by the other endpoints of the API, see points.
+func (api *API) receiptResponseFrom(receipt *Receipt) *ReceiptResponse {
+	resp := ReceiptResponse{
+		ID:           receipt.ID,
+		Retailer:     receipt.Retailer,
+		PurchaseDate: receipt.Purchased.Format("2006-01-02"),
+		PurchaseTime: receipt.Purchased.Format("15:04"),
+		Items:        make([]ProcessReceiptItem, 0, len(receipt.Items)),
+		Total:        formatAmount(receipt.Total),
+		Discount:     formatDiscount(receipt.Discount),
+		Points:       api.points(receipt),
+		Scored:       receipt.Scored || api.alwaysRecalculate,
+		Metadata:     receipt.Metadata,
+		UserID:       receipt.UserID,
+		Deleted:      receipt.Deleted,
+	}
+
+	if !receipt.PointsExpireAt.IsZero() {
+		resp.PointsExpireAt = receipt.PointsExpireAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, item := range receipt.Items {
+		resp.Items = append(resp.Items, ProcessReceiptItem{
+			ShortDescription: item.Description,
+			Price:            formatAmount(item.Price),
+		})
+	}
+
+	return &resp
+}
+
+// ListReceipts is an [http.HandlerFunc] that returns all stored receipts as a
+// JSON array of [ReceiptResponse], ordered by ID. Soft-deleted receipts are
+// excluded unless the `includeDeleted` query parameter is set to `true`.
+//
+// The array is streamed to the client as each receipt is encoded, rather than
+// buffering the entire response in memory. The receipts are snapshotted before
+// streaming begins so that receipts processed during the listing do not affect
+// it.
+func (api *API) ListReceipts(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	receipts, err := api.listReceipts(req)
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to list receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+
+	clearWriteDeadline(rw)
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	enc := api.encoder(rw, req)
+
+	rw.Write([]byte("["))
+
+	streamReceipts(rw, receipts, func(i int, receipt *Receipt) {
+		if i > 0 {
+			rw.Write([]byte(","))
+		}
+
+		enc.Encode(api.receiptResponseFrom(receipt))
+	}, nil)
+
+	rw.Write([]byte("]\n"))
+}
+
+// streamFlushInterval is the number of receipts written by streamReceipts
+// between flushes of the response.
+const streamFlushInterval = 100
+
+// streamReceipts calls write with each receipt and its index, flushing the
+// response periodically so that large responses are streamed to the client
+// rather than buffered in memory. If not nil, flush is called before each
+// flush of the response to flush any writes buffered by write.
+func streamReceipts(rw http.ResponseWriter, receipts []*Receipt, write func(i int, receipt *Receipt), flush func()) {
+	flusher, _ := rw.(http.Flusher)
+
+	for i, receipt := range receipts {
+		write(i, receipt)
+
+		if i%streamFlushInterval == streamFlushInterval-1 {
+			if flush != nil {
+				flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
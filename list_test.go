@@ -0,0 +1,109 @@
+package fetch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListReceipts(tt *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		receipts int
+	}{
+		{name: "empty", receipts: 0},
+		{name: "single", receipts: 1},
+		{name: "several hundred", receipts: 350},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI()
+
+			want := make(map[string]bool)
+			for i := 0; i < tc.receipts; i++ {
+				want[processReceipt(t, api, "testdata/readme-target-receipt.json")] = true
+			}
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/receipts", nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to list receipts, got %d status code, want 200", rw.Code)
+			}
+
+			var got []ReceiptResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse list response, got %v, want no error", err)
+			}
+
+			if len(got) != tc.receipts {
+				t.Fatalf("listed receipt count does not match, got %d, want %d", len(got), tc.receipts)
+			}
+
+			for i, receipt := range got {
+				if !want[receipt.ID] {
+					t.Fatalf("listed unexpected receipt, got %q, want one of the processed receipts", receipt.ID)
+				}
+
+				if i > 0 && got[i-1].ID >= receipt.ID {
+					t.Fatalf("listed receipts are not ordered by ID, got %q before %q", got[i-1].ID, receipt.ID)
+				}
+
+				if receipt.Total != "35.35" || receipt.Points != 28 || len(receipt.Items) != 5 {
+					t.Fatalf("listed receipt does not match, got %+v, want the readme target receipt", receipt)
+				}
+			}
+		})
+	}
+}
+
+func TestListReceiptsAlwaysRecalculate(t *testing.T) {
+	api := NewAPI(WithAlwaysRecalculate())
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	// The adjusted points are ignored in favor of the recalculated points, as
+	// by the other endpoints.
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/receipts/"+id+"/points", strings.NewReader(`{"points": 40, "reason": "customer satisfaction"}`))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to adjust points, got %d status code, want 200", rw.Code)
+	}
+
+	{
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("GET", "/receipts", nil))
+
+		var got []ReceiptResponse
+		if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to parse list response, got %v, want no error", err)
+		}
+
+		if len(got) != 1 || got[0].Points != 28 {
+			t.Fatalf("listed receipts do not match, got %+v, want 1 receipt with 28 points", got)
+		}
+	}
+
+	{
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("GET", "/receipts/export.csv", nil))
+
+		records, err := csv.NewReader(rw.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to parse export, got %v, want no error", err)
+		}
+
+		if len(records) != 2 || records[1][len(records[1])-1] != "28" {
+			t.Fatalf("exported receipts do not match, got %q, want 1 receipt with 28 points", records)
+		}
+	}
+}
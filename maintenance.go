@@ -0,0 +1,43 @@
+package fetch
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EnterMaintenance puts the API into maintenance mode, e.g. during deploys,
+// where endpoints that modify receipts respond with `503 Service Unavailable`
+// and a `Retry-After` header of retryAfter, while endpoints that only read
+// receipts continue to serve. It is safe to call concurrently with
+// [API.ServeHTTP].
+func (api *API) EnterMaintenance(retryAfter time.Duration) {
+	api.maintenance.Store(&retryAfter)
+}
+
+// ExitMaintenance takes the API out of maintenance mode. See
+// [API.EnterMaintenance].
+func (api *API) ExitMaintenance() {
+	api.maintenance.Store(nil)
+}
+
+// InMaintenance reports whether the API is in maintenance mode.
+func (api *API) InMaintenance() bool {
+	return api.maintenance.Load() != nil
+}
+
+// writes wraps a handler of an endpoint that modifies receipts so that it is
+// unavailable while the API is in maintenance mode.
+func (api *API) writes(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if retryAfter := api.maintenance.Load(); retryAfter != nil {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			rw.Header().Set("Retry-After", strconv.Itoa(seconds))
+			api.errorWithCode(rw, req, http.StatusServiceUnavailable, "maintenance", "API is in maintenance, retry after %d seconds", seconds)
+			return
+		}
+
+		handler(rw, req)
+	}
+}
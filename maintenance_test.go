@@ -0,0 +1,59 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/simple-receipt.json")
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rw
+	}
+
+	api.EnterMaintenance(90 * time.Second)
+
+	for _, req := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: "POST", path: "/receipts/process", body: string(body)},
+		{method: "PUT", path: fmt.Sprintf("/receipts/%s/points", id), body: `{"points": 1, "reason": "test"}`},
+		{method: "DELETE", path: fmt.Sprintf("/receipts/%s", id)},
+	} {
+		rw := serve(req.method, req.path, req.body)
+
+		if rw.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s %s status does not match in maintenance, got %d, want 503", req.method, req.path, rw.Code)
+		}
+		if got, want := rw.Header().Get("Retry-After"), "90"; got != want {
+			t.Fatalf("%s %s Retry-After does not match, got %q, want %q", req.method, req.path, got, want)
+		}
+	}
+
+	if got := getPoints(t, api, id); got != 31 {
+		t.Fatalf("points do not match in maintenance, got %d, want 31", got)
+	}
+
+	api.ExitMaintenance()
+
+	if rw := serve("POST", "/receipts/process", string(body)); rw.Code != http.StatusOK {
+		t.Fatalf("process receipt status does not match after maintenance, got %d, want 200", rw.Code)
+	}
+}
@@ -0,0 +1,130 @@
+package fetch
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LogSlowRequests returns a middleware that logs requests that take longer
+// than threshold to complete to the logger of the API at the warning level,
+// with the request "method", "path", and "elapsed" time as attributes.
+// Requests completing within the threshold are not logged, keeping logs quiet
+// under normal load while surfacing outliers.
+func (api *API) LogSlowRequests(threshold time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+
+			next.ServeHTTP(rw, req)
+
+			if elapsed := time.Since(start); elapsed > threshold {
+				api.logger.WarnContext(req.Context(), "slow request", "method", req.Method, "path", req.URL.Path, "elapsed", elapsed)
+			}
+		})
+	}
+}
+
+// RequestIDHeader is the request header carrying the ID of the request, e.g.
+// as assigned by a load balancer, which is included in access logs.
+const RequestIDHeader = "X-Request-ID"
+
+// AccessLogEntry is a single line of the access log written by
+// [LogAccessJSON].
+type AccessLogEntry struct {
+	// Timestamp is when the request was received, in RFC 3339 format with
+	// nanoseconds.
+	Timestamp string `json:"timestamp"`
+	// Method is the request method.
+	Method string `json:"method"`
+	// Path is the request path.
+	Path string `json:"path"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Bytes is the size of the response body in bytes.
+	Bytes int `json:"bytes"`
+	// DurationMS is the time taken to serve the request in milliseconds.
+	DurationMS float64 `json:"duration_ms"`
+	// RequestID is the [RequestIDHeader] of the request, empty if the request
+	// has none.
+	RequestID string `json:"request_id"`
+}
+
+// LogAccessJSON returns a middleware that writes an access log of every
+// request to w as JSON lines, one [AccessLogEntry] per request, for ingestion
+// by log pipelines. It complements the human-readable [API.LogSlowRequests].
+//
+// Writes to w are serialized, so w need not be safe for concurrent use.
+func LogAccessJSON(w io.Writer) func(http.Handler) http.Handler {
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			arw := &accessLogResponseWriter{ResponseWriter: rw}
+
+			next.ServeHTTP(arw, req)
+
+			status := arw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			b, _ := json.Marshal(&AccessLogEntry{
+				Timestamp:  start.UTC().Format(time.RFC3339Nano),
+				Method:     req.Method,
+				Path:       req.URL.Path,
+				Status:     status,
+				Bytes:      arw.bytes,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				RequestID:  req.Header.Get(RequestIDHeader),
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			w.Write(append(b, '\n'))
+		})
+	}
+}
+
+// accessLogResponseWriter is an [http.ResponseWriter] that records the status
+// and size of the response.
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rw *accessLogResponseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *accessLogResponseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+
+	return n, err
+}
+
+// Flush implements [http.Flusher] if the underlying response writer does, so
+// that streaming endpoints are unaffected.
+func (rw *accessLogResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer for [http.ResponseController].
+func (rw *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
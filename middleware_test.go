@@ -0,0 +1,162 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogSlowRequests(tt *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		sleep time.Duration
+		want  string
+	}{
+		{
+			name:  "slow request",
+			sleep: 20 * time.Millisecond,
+			want:  "level=WARN msg=\"slow request\" method=GET path=/receipts/abc/points elapsed=",
+		},
+		{
+			name:  "fast request",
+			sleep: 0,
+			want:  "",
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			api := NewAPI(WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey && len(groups) == 0 {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))))
+
+			handler := api.LogSlowRequests(10 * time.Millisecond)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				time.Sleep(tc.sleep)
+			}))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/receipts/abc/points", nil)
+
+			handler.ServeHTTP(rw, req)
+
+			got := buf.String()
+
+			if tc.want == "" && got != "" {
+				t.Fatalf("unexpected slow request log, got %q, want none", got)
+			}
+
+			if !strings.HasPrefix(got, tc.want) {
+				t.Fatalf("slow request log does not match, got %q, want prefix %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUse(t *testing.T) {
+	api := NewAPI()
+
+	var calls []string
+
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls = append(calls, name+" before")
+				next.ServeHTTP(rw, req)
+				calls = append(calls, name+" after")
+			})
+		}
+	}
+
+	api.Use(trace("first"))
+	api.Use(trace("second"))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/version", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get version, got %d status code, want 200", rw.Code)
+	}
+
+	want := "first before,second before,second after,first after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("middleware order does not match, got %q, want %q", got, want)
+	}
+}
+
+func TestLogAccessJSON(t *testing.T) {
+	api := NewAPI()
+
+	var buf bytes.Buffer
+	api.Use(LogAccessJSON(&buf))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+
+	api.ServeHTTP(rw, req)
+
+	line, err := buf.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("failed to read access log line, got %v, want no error", err)
+	}
+	if buf.Len() > 0 {
+		t.Fatalf("unexpected access log lines, got %q, want one line", buf.String())
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("failed to parse access log line %q, got %v, want no error", line, err)
+	}
+
+	for field, want := range map[string]any{
+		"method":     "GET",
+		"path":       "/receipts/7fb1377b-b223-49d9-a31a-5a02701dd310/points",
+		"status":     float64(http.StatusNotFound),
+		"bytes":      float64(rw.Body.Len()),
+		"request_id": "req-1",
+	} {
+		if got[field] != want {
+			t.Fatalf("access log %s does not match, got %v, want %v", field, got[field], want)
+		}
+	}
+
+	timestamp, _ := got["timestamp"].(string)
+	if _, err := time.Parse(time.RFC3339Nano, timestamp); err != nil {
+		t.Fatalf("failed to parse access log timestamp %q, got %v, want no error", timestamp, err)
+	}
+
+	if duration, ok := got["duration_ms"].(float64); !ok || duration < 0 {
+		t.Fatalf("access log duration_ms does not match, got %v, want non-negative number", got["duration_ms"])
+	}
+}
+
+func TestLogAccessJSONWithoutRequestID(t *testing.T) {
+	api := NewAPI()
+
+	var buf bytes.Buffer
+	api.Use(LogAccessJSON(&buf))
+
+	api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/version", nil))
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("failed to parse access log line %q, got %v, want no error", buf.Bytes(), err)
+	}
+
+	if id, ok := got["request_id"]; !ok || id != "" {
+		t.Fatalf("access log request_id does not match, got %v (present %v), want empty string", id, ok)
+	}
+}
@@ -0,0 +1,123 @@
+package fetch
+
+import (
+	"io"
+	"net/http"
+)
+
+// FieldNaming is the naming scheme of the JSON fields of receipts accepted by
+// the API and of the points returned by it. See [WithFieldNaming] for the
+// endpoints it applies to.
+type FieldNaming int
+
+const (
+	// CamelCase names fields in camelCase, e.g. "purchaseDate", as defined by
+	// the API specification. This is the default.
+	CamelCase FieldNaming = iota
+	// SnakeCase names fields in snake_case, e.g. "purchase_date".
+	SnakeCase
+)
+
+// WithFieldNaming sets the naming scheme of the JSON fields of receipts
+// submitted to the [ProcessReceipt] and [UpdateReceipt] endpoints, including
+// receipts fetched by `receiptUrl`, and of the points responses of the
+// [GetPoints], [AdjustPoints], and [UpdateReceipt] endpoints, for clients that
+// follow an alternate convention. Defaults to [CamelCase].
+//
+// The naming applies to those endpoints only, the receipt submission and
+// points lookup flow of the API specification. The fields of the
+// [ProcessReceipt] response and [AdjustPoints] request are single words named
+// the same in either scheme. All other endpoints, e.g. [GetReceipt],
+// [ListReceipts], the stats and admin endpoints, and error responses, always
+// use [CamelCase].
+func WithFieldNaming(naming FieldNaming) Option {
+	return func(api *API) {
+		api.fieldNaming = naming
+	}
+}
+
+// snakeProcessReceiptRequest is the [SnakeCase] form of
+// [ProcessReceiptRequest].
+type snakeProcessReceiptRequest struct {
+	Retailer     string                    `json:"retailer"`
+	PurchaseDate string                    `json:"purchase_date"`
+	PurchaseTime string                    `json:"purchase_time"`
+	Items        []snakeProcessReceiptItem `json:"items"`
+	Total        string                    `json:"total"`
+	ReceiptURL   string                    `json:"receipt_url,omitempty"`
+	Discount     string                    `json:"discount,omitempty"`
+	Metadata     map[string]string         `json:"metadata,omitempty"`
+	UserID       string                    `json:"user_id,omitempty"`
+}
+
+// snakeProcessReceiptItem is the [SnakeCase] form of [ProcessReceiptItem].
+type snakeProcessReceiptItem struct {
+	ShortDescription string `json:"short_description"`
+	Price            string `json:"price"`
+}
+
+// snakeGetPointsResponse is the [SnakeCase] form of [GetPointsResponse].
+type snakeGetPointsResponse struct {
+	Points  int                        `json:"points"`
+	Scored  bool                       `json:"scored"`
+	History []snakePointsEventResponse `json:"history,omitempty"`
+}
+
+// snakePointsEventResponse is the [SnakeCase] form of [PointsEventResponse].
+type snakePointsEventResponse struct {
+	Time      string `json:"time"`
+	OldPoints int    `json:"old_points"`
+	NewPoints int    `json:"new_points"`
+	Reason    string `json:"reason"`
+}
+
+// decodeReceipt decodes the receipt in the request body into prreq using the
+// configured field naming.
+func (api *API) decodeReceipt(body io.Reader, prreq *ProcessReceiptRequest) error {
+	if api.fieldNaming != SnakeCase {
+		return api.decode(body, prreq)
+	}
+
+	var snake snakeProcessReceiptRequest
+	if err := api.decode(body, &snake); err != nil {
+		return err
+	}
+
+	*prreq = ProcessReceiptRequest{
+		Retailer:     snake.Retailer,
+		PurchaseDate: snake.PurchaseDate,
+		PurchaseTime: snake.PurchaseTime,
+		Total:        snake.Total,
+		ReceiptURL:   snake.ReceiptURL,
+		Discount:     snake.Discount,
+		Metadata:     snake.Metadata,
+		UserID:       snake.UserID,
+	}
+
+	for _, item := range snake.Items {
+		prreq.Items = append(prreq.Items, ProcessReceiptItem(item))
+	}
+
+	return nil
+}
+
+// encodePoints writes the points response using the configured field naming.
+func (api *API) encodePoints(rw http.ResponseWriter, req *http.Request, resp *GetPointsResponse) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	if api.fieldNaming != SnakeCase {
+		api.encoder(rw, req).Encode(resp)
+		return
+	}
+
+	snake := snakeGetPointsResponse{
+		Points: resp.Points,
+		Scored: resp.Scored,
+	}
+
+	for _, event := range resp.History {
+		snake.History = append(snake.History, snakePointsEventResponse(event))
+	}
+
+	api.encoder(rw, req).Encode(&snake)
+}
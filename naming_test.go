@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSnakeCaseFieldNaming(t *testing.T) {
+	api := NewAPI(WithFieldNaming(SnakeCase))
+
+	body := `{
+		"retailer": "Target",
+		"purchase_date": "2022-01-01",
+		"purchase_time": "13:01",
+		"items": [
+			{"short_description": "Mountain Dew 12PK", "price": "6.49"},
+			{"short_description": "Emils Cheese Pizza", "price": "12.25"},
+			{"short_description": "Knorr Creamy Chicken", "price": "1.26"},
+			{"short_description": "Doritos Nacho Cheese", "price": "3.35"},
+			{"short_description": "   Klarbrunn 12-PK 12 FL OZ  ", "price": "12.00"}
+		],
+		"total": "35.35"
+	}`
+
+	id := processReceiptBody(t, api, body)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points?history=true", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get points, got %d status code, want 200", rw.Code)
+	}
+
+	var got map[string]any
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse points response, got %v, want no error", err)
+	}
+
+	if points := got["points"]; points != 28.0 {
+		t.Fatalf("points do not match, got %v, want 28", points)
+	}
+
+	history, _ := got["history"].([]any)
+	if len(history) != 1 {
+		t.Fatalf("points history does not match, got %v, want 1 event", got["history"])
+	}
+
+	event, _ := history[0].(map[string]any)
+	for _, key := range []string{"old_points", "new_points"} {
+		if _, ok := event[key]; !ok {
+			t.Fatalf("points event does not match, got %v, want %q field", event, key)
+		}
+	}
+}
+
+func TestCamelCaseFieldNamingRejectsSnakeCase(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(`{"retailer": "Target", "purchase_date": "2022-01-01", "purchase_time": "13:01", "total": "1.00"}`))
+
+	api.ServeHTTP(rw, req)
+
+	if got, want := rw.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("status code does not match, got %d, want %d", got, want)
+	}
+}
+
+func TestSnakeCaseReceiptURL(t *testing.T) {
+	api := NewAPI(WithFieldNaming(SnakeCase))
+
+	var got ProcessReceiptRequest
+	if err := api.decodeReceipt(strings.NewReader(`{"receipt_url": "https://receipts.example.com/1.json"}`), &got); err != nil {
+		t.Fatalf("failed to decode receipt, got %v, want no error", err)
+	}
+
+	if want := "https://receipts.example.com/1.json"; got.ReceiptURL != want {
+		t.Fatalf("receipt URL does not match, got %q, want %q", got.ReceiptURL, want)
+	}
+}
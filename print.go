@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// printWidth is the width in characters of receipts rendered by
+// [PrintReceipt].
+const printWidth = 40
+
+// PrintReceipt is an [http.HandlerFunc] that returns the receipt specified by
+// the `id` path parameter rendered as plain text resembling a paper receipt,
+// for support agents. The rendering includes the retailer, the purchase date
+// and time, the items with their prices, the total, and the points of the
+// receipt.
+//
+// If no receipt exists for the given `id` the endpoint responds with `404 Not
+// Found`, or `410 Gone` if the receipt has been deleted.
+func (api *API) PrintReceipt(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := api.store.Get(req.Context(), id)
+	if err == nil && receipt.Deleted {
+		err = errDeleted
+	}
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	printReceipt(rw, receipt, api.points(receipt))
+}
+
+// printReceipt renders the receipt with the points as plain text resembling a
+// paper receipt.
+func printReceipt(w io.Writer, receipt *Receipt, points int) {
+	rule := strings.Repeat("-", printWidth)
+
+	fmt.Fprintln(w, printCentered(strings.TrimSpace(receipt.Retailer)))
+	fmt.Fprintln(w, printCentered(receipt.Purchased.Format("2006-01-02 15:04")))
+	fmt.Fprintln(w, rule)
+
+	for _, item := range receipt.Items {
+		fmt.Fprintln(w, printLine(strings.TrimSpace(item.Description), formatAmount(item.Price)))
+	}
+
+	fmt.Fprintln(w, rule)
+
+	if discount := receipt.Total - receipt.DiscountedTotal(); discount > 0 {
+		fmt.Fprintln(w, printLine("SUBTOTAL", formatAmount(receipt.Total)))
+		fmt.Fprintln(w, printLine("DISCOUNT "+formatDiscount(receipt.Discount), formatAmount(-discount)))
+	}
+
+	fmt.Fprintln(w, printLine("TOTAL", formatAmount(receipt.DiscountedTotal())))
+	fmt.Fprintln(w, rule)
+	fmt.Fprintln(w, printLine("POINTS", fmt.Sprint(points)))
+}
+
+// printCentered centers the text within the width of the receipt, truncating
+// it if too long.
+func printCentered(text string) string {
+	text = printTruncate(text, printWidth)
+
+	return strings.Repeat(" ", (printWidth-utf8.RuneCountInString(text))/2) + text
+}
+
+// printLine renders a line of the receipt with the label left-aligned and the
+// value right-aligned, truncating the label if the line is too long.
+func printLine(label, value string) string {
+	label = printTruncate(label, printWidth-utf8.RuneCountInString(value)-1)
+	pad := printWidth - utf8.RuneCountInString(label) - utf8.RuneCountInString(value)
+
+	return label + strings.Repeat(" ", max(pad, 1)) + value
+}
+
+// printTruncate truncates the text to at most width characters.
+func printTruncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+
+	return string([]rune(text)[:max(width, 0)])
+}
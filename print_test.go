@@ -0,0 +1,74 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintReceipt(t *testing.T) {
+	api := NewAPI()
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/print", id), nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to print receipt, got %d status code, want 200", rw.Code)
+	}
+
+	if got, want := rw.Header().Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+		t.Fatalf("content type does not match, got %q, want %q", got, want)
+	}
+
+	got := rw.Body.String()
+
+	for _, want := range []string{
+		"                 Target\n",
+		"            2022-01-01 13:01\n",
+		"Mountain Dew 12PK                   6.49\n",
+		"Klarbrunn 12-PK 12 FL OZ           12.00\n",
+		"TOTAL                              35.35\n",
+		"POINTS                                28\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("printed receipt does not match, got:\n%s\nwant it to contain %q", got, want)
+		}
+	}
+}
+
+func TestPrintReceiptDiscount(t *testing.T) {
+	receipt := &Receipt{
+		Retailer:  "M&M Corner Market",
+		Purchased: time.Date(2022, 3, 20, 14, 33, 0, 0, time.UTC),
+		Items: []ReceiptItem{
+			{Description: "An item description far too long to fit on the line", Price: 605},
+		},
+		Total:    905,
+		Discount: Discount{Percent: 10},
+	}
+
+	var b strings.Builder
+	printReceipt(&b, receipt, 109)
+
+	want := `           M&M Corner Market
+            2022-03-20 14:33
+----------------------------------------
+An item description far too long to 6.05
+----------------------------------------
+SUBTOTAL                            9.05
+DISCOUNT 10%                       -0.91
+TOTAL                               8.14
+----------------------------------------
+POINTS                               109
+`
+	if got := b.String(); got != want {
+		t.Fatalf("printed receipt does not match, got:\n%s\nwant:\n%s", got, want)
+	}
+}
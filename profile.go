@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// WithProfiling enables the `net/http/pprof` profiling endpoints under
+// `/debug/pprof/` of the handler returned by [API.AdminHandler], e.g. to
+// capture heap and CPU profiles under load. The endpoints are never served by
+// the public API. Requests must present the token as a bearer token in the
+// `Authorization` header, e.g. "Authorization: Bearer <token>". The token
+// also gates the [API.Reset] endpoint if enabled. An empty token disables
+// profiling.
+func WithProfiling(token string) Option {
+	return func(api *API) {
+		api.profilingToken = token
+	}
+}
+
+// AdminHandler returns an [http.Handler] of the admin endpoints of the API,
+// which must be served separately from the API itself, e.g. on a listener
+// reachable only from within the deployment. The profiling endpoints are only
+// available when the API is configured with [WithProfiling], and the
+// `/admin/reset` endpoint of [API.Reset] when configured with [WithReset], all
+// other requests respond with `404 Not Found`.
+func (api *API) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	if api.reset {
+		reset := api.options(api.writes(api.Reset), "POST")
+		if api.profilingToken != "" {
+			reset = api.adminAuth(reset)
+		}
+
+		mux.Handle("/admin/reset", reset)
+	}
+
+	if api.profilingToken != "" {
+		mux.Handle("/debug/pprof/", api.adminAuth(pprof.Index))
+		mux.Handle("/debug/pprof/cmdline", api.adminAuth(pprof.Cmdline))
+		mux.Handle("/debug/pprof/profile", api.adminAuth(pprof.Profile))
+		mux.Handle("/debug/pprof/symbol", api.adminAuth(pprof.Symbol))
+		mux.Handle("/debug/pprof/trace", api.adminAuth(pprof.Trace))
+	}
+
+	mux.HandleFunc("/", api.NotFound)
+
+	return mux
+}
+
+// adminAuth wraps the handler to respond with `401 Unauthorized` unless the
+// request presents the profiling token as a bearer token.
+func (api *API) adminAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.profilingToken)) != 1 {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			api.WriteError(rw, req, http.StatusUnauthorized, "missing or invalid admin token")
+			return
+		}
+
+		handler(rw, req)
+	}
+}
@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProfiling(tt *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		auth   string
+		status int
+	}{
+		{name: "disabled", status: http.StatusNotFound},
+		{name: "disabled with token", auth: "Bearer secret", status: http.StatusNotFound},
+		{name: "enabled", opts: []Option{WithProfiling("secret")}, auth: "Bearer secret", status: http.StatusOK},
+		{name: "missing token", opts: []Option{WithProfiling("secret")}, status: http.StatusUnauthorized},
+		{name: "invalid token", opts: []Option{WithProfiling("secret")}, auth: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "empty token", opts: []Option{WithProfiling("")}, auth: "Bearer ", status: http.StatusNotFound},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(tc.opts...)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+
+			api.AdminHandler().ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("failed to get profiles, got %d status code, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestProfilingNotPublic(t *testing.T) {
+	api := NewAPI(WithProfiling("secret"), WithDebug())
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("profiles served by public API, got %d status code, want 404", rw.Code)
+	}
+}
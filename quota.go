@@ -0,0 +1,144 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LimitDailyReceipts returns a middleware that limits the number of receipts
+// stored from each client IP address to limit per day, to prevent abuse of
+// the store. Receipts count towards the quota of the client when stored by
+// the [ProcessReceipt] or [ImportReceipts] endpoints, so only successfully
+// stored receipts count. Client IP addresses behind proxies are only
+// recognized if the proxies are trusted, see [WithTrustedProxies].
+//
+// Receipts exceeding the quota are rejected with `429 Too Many Requests` and
+// a Retry-After of the time remaining until the quota resets at midnight UTC,
+// as determined by the clock of the API, or with an `error` event by the
+// [ImportReceipts] endpoint.
+//
+// Counts are kept in memory and discarded when the day changes, so the memory
+// used is bounded by the number of clients seen in a single day.
+func (api *API) LimitDailyReceipts(limit int) func(http.Handler) http.Handler {
+	quota := &dailyQuota{limit: limit}
+
+	api.mu.Lock()
+	api.quotas = append(api.quotas, quota)
+	api.mu.Unlock()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			cq := &clientQuota{quota: quota, ip: api.clientIP(req)}
+
+			next.ServeHTTP(rw, req.WithContext(context.WithValue(req.Context(), quotaKey{}, cq)))
+		})
+	}
+}
+
+// quotaKey is the request context key of the [clientQuota] of the client of
+// the request.
+type quotaKey struct{}
+
+// clientQuota is the daily quota of a single client IP address.
+type clientQuota struct {
+	quota *dailyQuota
+	ip    string
+}
+
+// quotaExceededError is returned when storing a receipt would exceed the
+// daily quota of the client.
+type quotaExceededError struct {
+	limit int
+	// retryAfter is the time remaining until the quota resets.
+	retryAfter time.Duration
+}
+
+func (e *quotaExceededError) Error() string {
+	return fmt.Sprintf("daily quota of %d receipts exceeded, retry after midnight UTC", e.limit)
+}
+
+// reserveQuota counts a receipt towards the daily quota of the client of the
+// request context, if limited, returning a function releasing the
+// reservation if the receipt is not stored after all. A *quotaExceededError
+// is returned if the quota is exceeded.
+func (api *API) reserveQuota(ctx context.Context) (func(), error) {
+	cq, _ := ctx.Value(quotaKey{}).(*clientQuota)
+	if cq == nil {
+		return func() {}, nil
+	}
+
+	now := api.now().UTC()
+
+	if !cq.quota.reserve(cq.ip, now) {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return nil, &quotaExceededError{limit: cq.quota.limit, retryAfter: midnight.Sub(now)}
+	}
+
+	return func() { cq.quota.release(cq.ip, now) }, nil
+}
+
+// quotaError writes the `429 Too Many Requests` response of the exceeded
+// quota.
+func (api *API) quotaError(rw http.ResponseWriter, req *http.Request, err *quotaExceededError) {
+	rw.Header().Set("Retry-After", strconv.Itoa(int(err.retryAfter.Seconds())+1))
+	api.errorWithCode(rw, req, http.StatusTooManyRequests, "daily_quota_exceeded", "%v", err)
+}
+
+// dailyQuota counts the receipts stored from each client IP address on the
+// current day.
+type dailyQuota struct {
+	limit int
+
+	mu sync.Mutex
+	// day is the current day, as the Unix time of midnight UTC.
+	day    int64
+	counts map[string]int
+}
+
+// reserve counts a receipt of the client IP address towards the quota of the
+// day of now, reporting false if the quota is exceeded. Counts of previous
+// days are discarded.
+func (q *dailyQuota) reserve(ip string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if day := now.Truncate(24 * time.Hour).Unix(); day != q.day || q.counts == nil {
+		q.day = day
+		q.counts = make(map[string]int)
+	}
+
+	if q.counts[ip] >= q.limit {
+		return false
+	}
+
+	q.counts[ip]++
+
+	return true
+}
+
+// reset discards the counts of all client IP addresses.
+func (q *dailyQuota) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.counts = nil
+}
+
+// release returns a receipt reserved on the day of now to the quota of the
+// client IP address, unless the day has since changed.
+func (q *dailyQuota) release(ip string, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if now.Truncate(24*time.Hour).Unix() != q.day || q.counts[ip] == 0 {
+		return
+	}
+
+	if q.counts[ip]--; q.counts[ip] == 0 {
+		delete(q.counts, ip)
+	}
+}
@@ -0,0 +1,148 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitDailyReceipts(t *testing.T) {
+	now := time.Date(2022, 1, 2, 23, 59, 0, 0, time.UTC)
+
+	api := NewAPI(WithClock(func() time.Time { return now }))
+	api.Use(api.LimitDailyReceipts(2))
+
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	process := func(remoteAddr, body string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+
+		api.ServeHTTP(rw, req)
+
+		return rw
+	}
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		body       string
+		status     int
+	}{
+		{name: "first", remoteAddr: "192.0.2.1:1234", body: string(body), status: http.StatusOK},
+		{name: "invalid not counted", remoteAddr: "192.0.2.1:1234", body: `{`, status: http.StatusBadRequest},
+		{name: "second from other port", remoteAddr: "192.0.2.1:5678", body: string(body), status: http.StatusOK},
+		{name: "exceeded", remoteAddr: "192.0.2.1:1234", body: string(body), status: http.StatusTooManyRequests},
+		{name: "other client", remoteAddr: "192.0.2.2:1234", body: string(body), status: http.StatusOK},
+	} {
+		if rw := process(tc.remoteAddr, tc.body); rw.Code != tc.status {
+			t.Fatalf("%s: process receipt status does not match, got %d, want %d", tc.name, rw.Code, tc.status)
+		}
+	}
+
+	rw := process("192.0.2.1:1234", string(body))
+	if got := rw.Header().Get("Retry-After"); got != "61" {
+		t.Fatalf("Retry-After does not match, got %q, want %q", got, "61")
+	}
+
+	// The quota resets at midnight UTC.
+	now = now.Add(time.Minute)
+
+	if rw := process("192.0.2.1:1234", string(body)); rw.Code != http.StatusOK {
+		t.Fatalf("process receipt status after midnight does not match, got %d, want 200", rw.Code)
+	}
+
+	// Other endpoints are not limited.
+	rw = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("health status does not match, got %d, want 200", rw.Code)
+	}
+}
+
+func TestLimitDailyReceiptsTrustedProxies(tt *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		status int
+	}{
+		// Without trusted proxies all clients of the proxy share a quota.
+		{name: "untrusted", status: http.StatusTooManyRequests},
+		{name: "trusted", opts: []Option{WithTrustedProxies(netip.MustParsePrefix("10.0.0.1/32"))}, status: http.StatusOK},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(tc.opts...)
+			api.Use(api.LimitDailyReceipts(1))
+
+			var rw *httptest.ResponseRecorder
+			for _, client := range []string{"192.0.2.1", "192.0.2.2"} {
+				rw = httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(string(body)))
+				req.RemoteAddr = "10.0.0.1:1234"
+				req.Header.Set("X-Forwarded-For", client)
+
+				api.ServeHTTP(rw, req)
+			}
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status of second client does not match, got %d, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+}
+
+func TestLimitDailyReceiptsImport(t *testing.T) {
+	api := NewAPI()
+	api.Use(api.LimitDailyReceipts(2))
+
+	receipt := `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/receipts/import", strings.NewReader(strings.Repeat(receipt+"\n", 3)))
+
+	api.ServeHTTP(rw, req)
+
+	var summary ImportSummary
+	for _, line := range strings.Split(rw.Body.String(), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &summary); err != nil {
+				t.Fatalf("failed to parse import event, got %v, want no error", err)
+			}
+		}
+	}
+
+	if summary.Processed != 2 || summary.Failed != 1 {
+		t.Fatalf("import summary does not match, got %+v, want 2 processed and 1 failed", summary)
+	}
+	if !strings.Contains(rw.Body.String(), "daily quota of 2 receipts exceeded") {
+		t.Fatalf("import events do not report the exceeded quota, got %q", rw.Body.String())
+	}
+
+	// Imported receipts count towards the quota of processed receipts.
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/receipts/process", strings.NewReader(receipt))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusTooManyRequests {
+		t.Fatalf("process receipt status does not match, got %d, want 429", rw.Code)
+	}
+}
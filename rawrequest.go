@@ -0,0 +1,100 @@
+package fetch
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// WithRawRequests configures the API to store the raw body of each request
+// to the [ProcessReceipt] endpoint on the processed receipt, e.g. for
+// resolving disputes over what a client submitted. Bodies larger than maxSize
+// bytes are truncated to maxSize bytes. The raw bodies are returned by the
+// [DebugRawRequest] endpoint.
+func WithRawRequests(maxSize int) Option {
+	return func(api *API) {
+		api.maxRawRequestSize = maxSize
+	}
+}
+
+// rawCapture is an [io.Writer] capturing at most max bytes written to it,
+// recording whether more were written.
+type rawCapture struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *rawCapture) Write(b []byte) (int, error) {
+	if n := c.max - c.buf.Len(); len(b) > n {
+		c.buf.Write(b[:n])
+		c.truncated = true
+		return len(b), nil
+	}
+
+	return c.buf.Write(b)
+}
+
+// captureRaw returns a reader of the request body which captures the bytes
+// read from it, if raw requests are stored, along with the capture. The
+// capture is nil if raw requests are not stored.
+func (api *API) captureRaw(body io.Reader) (io.Reader, *rawCapture) {
+	if api.maxRawRequestSize <= 0 {
+		return body, nil
+	}
+
+	capture := &rawCapture{max: api.maxRawRequestSize}
+
+	return io.TeeReader(body, capture), capture
+}
+
+// finish reads the remainder of the body, up to the capture size, since
+// decoding may stop short of the end of the body, and returns the captured
+// bytes.
+func (c *rawCapture) finish(body io.Reader) ([]byte, bool) {
+	io.Copy(io.Discard, io.LimitReader(body, int64(c.max-c.buf.Len()+1)))
+
+	return bytes.Clone(c.buf.Bytes()), c.truncated
+}
+
+// RawRequestTruncatedHeader is the response header of the [DebugRawRequest]
+// endpoint that is set to `true` if the raw request was truncated.
+const RawRequestTruncatedHeader = "X-Raw-Request-Truncated"
+
+// DebugRawRequest is an [http.HandlerFunc] that returns the raw request body
+// submitted to the [ProcessReceipt] endpoint for the receipt specified by the
+// `id` path parameter, as stored when the API is configured with
+// [WithRawRequests]. The endpoint is only available when the API is
+// configured with [WithDebug].
+//
+// If no receipt exists for the given `id`, or no raw request was stored for
+// it, the endpoint responds with `404 Not Found`.
+func (api *API) DebugRawRequest(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	id, ok := api.receiptID(rw, req)
+	if !ok {
+		return
+	}
+
+	receipt, err := api.store.Get(req.Context(), id)
+	if err != nil {
+		api.storeError(rw, req, id, err)
+		return
+	}
+
+	if receipt.RawRequest == nil {
+		api.WriteError(rw, req, http.StatusNotFound, "no raw request stored for receipt with ID %q", id)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if receipt.RawRequestTruncated {
+		rw.Header().Set(RawRequestTruncatedHeader, "true")
+	}
+
+	rw.Write(receipt.RawRequest)
+}
@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRawRequests(tt *testing.T) {
+	// Unusual but valid formatting and trailing whitespace which would be
+	// lost if the raw request were re-encoded from the decoded request.
+	body := "{ \"retailer\":\"Target\",\n\t\"purchaseDate\": \"2022-01-01\", \"purchaseTime\": \"13:01\",\n\t\"items\": [], \"total\": \"1.00\" }\n\n"
+
+	for _, tc := range []struct {
+		name          string
+		maxSize       int
+		want          string
+		wantTruncated bool
+	}{
+		{name: "complete", maxSize: 1024, want: body},
+		{name: "exact size", maxSize: len(body), want: body},
+		{name: "truncated", maxSize: 16, want: body[:16], wantTruncated: true},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(WithDebug(), WithRawRequests(tc.maxSize))
+
+			id := processReceiptBody(t, api, body)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/debug/receipts/"+id+"/raw", nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to get raw request, got %d status code, want 200", rw.Code)
+			}
+			if got := rw.Body.String(); got != tc.want {
+				t.Fatalf("raw request does not match, got %q, want %q", got, tc.want)
+			}
+			if got := rw.Header().Get(RawRequestTruncatedHeader) == "true"; got != tc.wantTruncated {
+				t.Fatalf("raw request truncation does not match, got %t, want %t", got, tc.wantTruncated)
+			}
+		})
+	}
+
+	tt.Run("after update", func(t *testing.T) {
+		t.Parallel()
+
+		api := NewAPI(WithDebug(), WithRawRequests(1024))
+
+		id := processReceiptBody(t, api, body)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/receipts/"+id, strings.NewReader(`{"retailer": "Walmart", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "2.00"}`))
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to update receipt, got %d status code, want 200", rw.Code)
+		}
+
+		rw = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/debug/receipts/"+id+"/raw", nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("failed to get raw request, got %d status code, want 200", rw.Code)
+		}
+		if got := rw.Body.String(); got != body {
+			t.Fatalf("raw request does not match, got %q, want %q", got, body)
+		}
+	})
+
+	tt.Run("not stored", func(t *testing.T) {
+		t.Parallel()
+
+		api := NewAPI(WithDebug())
+
+		id := processReceiptBody(t, api, body)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/debug/receipts/"+id+"/raw", nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("raw request status does not match, got %d, want 404", rw.Code)
+		}
+	})
+
+	tt.Run("debug disabled", func(t *testing.T) {
+		t.Parallel()
+
+		api := NewAPI(WithRawRequests(1024))
+
+		id := processReceiptBody(t, api, body)
+
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/debug/receipts/"+id+"/raw", nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("raw request status does not match, got %d, want 404", rw.Code)
+		}
+	})
+}
@@ -3,9 +3,10 @@ package fetch
 import (
 	"crypto/rand"
 	"fmt"
-	"strings"
+	"maps"
+	"math/big"
+	"slices"
 	"time"
-	"unicode"
 )
 
 // Receipt represents the purchase of one or more items at a specific retailer
@@ -24,6 +25,8 @@ type Receipt struct {
 	// as cents. Tax is either not included, or assumed to be incorporated into
 	// the cost of individual line items.
 	Total int
+	// Discount is the discount applied to the total of the receipt, if any.
+	Discount Discount
 	// Points are the number of Fetch rewards points assigned to the
 	// receipt.
 	//
@@ -32,6 +35,94 @@ type Receipt struct {
 	// fraud, returns, customer satisfaction, bugs, etc. where manual
 	// adjustments will be required.
 	Points int
+	// Metadata is arbitrary client data attached to the receipt which never
+	// affects the points awarded.
+	Metadata map[string]string
+	// UserID is the ID of the user that submitted the receipt, if any.
+	UserID string
+	// NewCustomer marks the receipt as the first receipt of its user, which
+	// is awarded the [Ruleset.NewCustomerBonus].
+	NewCustomer bool
+	// ContentHash is the hash of the canonical JSON of the receipt content,
+	// see [CanonicalJSON], if computed when processed, used to prevent
+	// duplicate submissions.
+	ContentHash string
+	// Deleted marks the receipt as soft-deleted. Soft-deleted receipts are
+	// retained for reporting but are otherwise treated as gone.
+	Deleted bool
+	// DeletedAt is when the receipt was soft-deleted.
+	DeletedAt time.Time
+	// PointsExpireAt is when the points of the receipt expire, or the zero
+	// time if they never expire. See [WithPointsTTL].
+	PointsExpireAt time.Time
+	// Scored is true once points have been assigned to the receipt, which
+	// distinguishes a receipt legitimately worth zero points from one whose
+	// points have not been calculated.
+	Scored bool
+	// PointsHistory is the audit trail of changes to the points assigned to
+	// the receipt, starting with the initial calculation, in chronological
+	// order.
+	PointsHistory []PointsEvent
+	// Breakdown is the breakdown of the points by rule as calculated when
+	// the points were assigned, if persisted. See [WithPersistBreakdowns].
+	Breakdown []RulePoints
+	// RawRequest is the raw request body the receipt was submitted with, if
+	// stored. See [WithRawRequests].
+	RawRequest []byte
+	// RawRequestTruncated is true if the raw request body exceeded the
+	// maximum size and RawRequest holds only its beginning.
+	RawRequestTruncated bool
+}
+
+// Discount is a discount applied to the total of a receipt, either a
+// percentage of the total or a fixed amount. The zero value is no discount.
+type Discount struct {
+	// Percent is the percentage of the total discounted, from 0 to 100.
+	Percent int
+	// Amount is the fixed amount discounted, represented as cents.
+	Amount int
+}
+
+// DiscountedTotal returns the total of the receipt after the discount,
+// represented as cents, which is never negative.
+//
+// Percentage discounts are rounded half up to the nearest cent, e.g. a 15%
+// discount of 0.10 is 0.02 rather than 0.015, keeping all math in integer
+// cents.
+func (r *Receipt) DiscountedTotal() int {
+	discount := r.Discount.Amount + (r.Total*r.Discount.Percent+50)/100
+
+	return max(r.Total-discount, 0)
+}
+
+// exactDiscountedTotal returns the total of the receipt after the discount as
+// an exact number of cents, without rounding percentage discounts to the
+// nearest cent, which is never negative.
+func (r *Receipt) exactDiscountedTotal() *big.Rat {
+	total := new(big.Rat).SetInt64(int64(r.Total))
+
+	discount := new(big.Rat).SetFrac64(int64(r.Total)*int64(r.Discount.Percent), 100)
+	discount.Add(discount, new(big.Rat).SetInt64(int64(r.Discount.Amount)))
+
+	total.Sub(total, discount)
+	if total.Sign() < 0 {
+		return new(big.Rat)
+	}
+
+	return total
+}
+
+// PointsEvent records a single change to the points assigned to a receipt.
+type PointsEvent struct {
+	// Time is when the change was made.
+	Time time.Time
+	// OldPoints is the point value before the change.
+	OldPoints int
+	// NewPoints is the point value after the change.
+	NewPoints int
+	// Reason is the human-readable reason for the change, e.g. "initial
+	// calculation" or the justification given for a manual adjustment.
+	Reason string
 }
 
 // ReceiptItem is an individual line item on a receipt.
@@ -54,9 +145,41 @@ func NewReceipt() (*Receipt, error) {
 	}, nil
 }
 
+// Clone returns a deep copy of the receipt which may be modified without
+// affecting the original.
+func (r *Receipt) Clone() *Receipt {
+	clone := *r
+	clone.Items = slices.Clone(r.Items)
+	clone.Metadata = maps.Clone(r.Metadata)
+	clone.PointsHistory = slices.Clone(r.PointsHistory)
+	clone.Breakdown = slices.Clone(r.Breakdown)
+	clone.RawRequest = slices.Clone(r.RawRequest)
+
+	return &clone
+}
+
+// SetPoints assigns the points to the receipt, marking it as scored, and
+// records the change in the points history of the receipt.
+func (r *Receipt) SetPoints(points int, reason string, at time.Time) {
+	r.PointsHistory = append(r.PointsHistory, PointsEvent{
+		Time:      at,
+		OldPoints: r.Points,
+		NewPoints: points,
+		Reason:    reason,
+	})
+	r.Points = points
+	r.Scored = true
+}
+
 // CalculatePoints determines the number of Fetch rewards points that a given
-// receipt is worth based on data points such as the retailer name, purchase
-// date and time, items purchased, etc.
+// receipt is worth using the [DefaultRuleset]. See [Ruleset.CalculatePoints].
+func CalculatePoints(receipt *Receipt) int {
+	return DefaultRuleset().CalculatePoints(receipt)
+}
+
+// CalculatePoints determines the number of Fetch rewards points that a given
+// receipt is worth under the ruleset based on data points such as the retailer
+// name, purchase date and time, items purchased, etc.
 //
 // CalculatePoints does NOT recalculate points if the given receipt already has
 // points assigned to it. We do this to avoid retroactively changing point
@@ -64,50 +187,122 @@ func NewReceipt() (*Receipt, error) {
 // changes which may cause discrepencies in accounting when comparing points
 // spent vs. points earned.
 //
-// Current Point Rules:
-//   - One point for every alphanumeric character in the retailer name.
+// Current Point Rules, as configured by the ruleset:
+//   - One point for every character of the RetailerCharacters class in the
+//     retailer name, alphanumeric by default.
+//   - The RetailerBonuses points if the retailer is a partner retailer.
 //   - 50 points if the total is a round dollar amount with no cents.
-//   - 25 points if the total is a multiple of 0.25.
-//   - 5 points for every two items on the receipt.
-//   - If the trimmed length of the item description is a multiple of 3, multiply
-//     the price by 0.2 and round up to the nearest integer. The result is the
-//     number of points earned.
+//   - 25 points if the total is a multiple of 0.25, unless already awarded
+//     the round dollar points under ExclusiveTotalRules.
+//   - ItemPairPoints, 5 by default, for every two items on the receipt, plus
+//     LeftoverItemPoints, none by default, for the leftover item of an odd
+//     number of items.
+//   - If the trimmed length of the item description is a multiple of 3, and
+//     at least MinDescriptionLength, multiply the price by 0.2 and round up to
+//     the nearest integer. The result is the number of points earned.
 //   - 6 points if the day in the purchase date is odd.
 //   - 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-func CalculatePoints(receipt *Receipt) int {
+//   - WeekdayBonus points if the purchase was made on one of the
+//     BonusWeekdays.
+//
+// The points of the rules are then adjusted in order:
+//   - Receipts with no items are scored zero points under
+//     [EmptyReceiptsZero].
+//   - The points are multiplied by the PointsMultiplier, rounding half up.
+//   - The NewCustomerBonus is added for the first receipt of a new customer,
+//     see [Receipt.NewCustomer].
+//   - The points are capped at MaxPointsPerReceipt.
+func (rs *Ruleset) CalculatePoints(receipt *Receipt) int {
 	// Skip point calculation if points are already assigned and return
 	// existing point value. If recalcating points is required then the points
-	// should be zero'd out manually to make this desire explicit.
-	if receipt.Points > 0 {
+	// and scored flag should be zero'd out manually to make this desire
+	// explicit.
+	if receipt.Scored || receipt.Points > 0 {
 		return receipt.Points
 	}
 
-	var points int
+	return rs.calculatePoints(receipt)
+}
 
-	// One point for every alphanumeric character in the retailer name.
+// RulePoints are the points awarded to a receipt by a single point rule.
+type RulePoints struct {
+	// Rule is the name of the point rule, e.g. "retailerName".
+	Rule string `json:"rule"`
+	// Points are the points awarded by the rule.
+	Points int `json:"points"`
+}
+
+// pointsCapRule is the name of the rule in the breakdown of the points of a
+// receipt recording the points removed by [Ruleset.MaxPointsPerReceipt].
+const pointsCapRule = "pointsCap"
+
+// calculatePoints determines the number of Fetch rewards points that a given
+// receipt is worth under the ruleset, regardless of any points already
+// assigned to the receipt.
+func (rs *Ruleset) calculatePoints(receipt *Receipt) int {
+	points, _ := rs.CalculatePointsWithBreakdown(receipt)
+	return points
+}
+
+// CalculatePointsWithBreakdown determines the number of Fetch rewards points
+// that a given receipt is worth under the ruleset along with the points
+// awarded by each rule, in the order the rules are applied, e.g. to explain
+// the points to a customer.
+//
+// Unlike [Ruleset.CalculatePoints] the points are always calculated from the
+// receipt data, regardless of any points already assigned to the receipt.
+func (rs *Ruleset) CalculatePointsWithBreakdown(receipt *Receipt) (int, []RulePoints) {
+	var breakdown []RulePoints
+	award := func(rule string, points int) {
+		breakdown = append(breakdown, RulePoints{Rule: rule, Points: points})
+	}
+
+	// One point for every alphanumeric character, or those of the configured
+	// character class, in the retailer name.
+	var retailer int
 	for _, r := range receipt.Retailer {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			points++
+		if rs.retailerCharacter(r) {
+			retailer++
 		}
 	}
+	award("retailerName", retailer)
+
+	// Bonus points for partner retailers, if so configured.
+	if len(rs.RetailerBonuses) > 0 {
+		award("retailerBonus", rs.retailerBonus(receipt.Retailer))
+	}
+
+	// The total rules apply to the total after any discount, either rounded
+	// to the nearest cent or exact if so configured.
+	roundDollar, quarterMultiple := rs.totalMultiples(receipt)
 
 	// 50 points if the total is a round dollar amount with no cents.
-	if receipt.Total%100 == 0 {
-		points += 50
+	var dollar int
+	if roundDollar {
+		dollar = 50
 	}
+	award("roundDollarTotal", dollar)
 
-	// 25 points if the total is a multiple of 0.25.
-	if receipt.Total%25 == 0 {
-		points += 25
+	// 25 points if the total is a multiple of 0.25, unless already awarded
+	// the round dollar points under exclusive total rules.
+	var quarter int
+	if quarterMultiple && !(roundDollar && rs.ExclusiveTotalRules) {
+		quarter = 25
 	}
+	award("quarterTotal", quarter)
 
-	// 5 points for every two items on the receipt.
-	points += 5 * (len(receipt.Items) / 2)
+	// ItemPairPoints for every two items on the receipt, plus
+	// LeftoverItemPoints for the leftover item of an odd number of items.
+	items := rs.countedItems(receipt)
+	award("itemPairs", rs.ItemPairPoints*(items/2))
+	award("leftoverItem", rs.LeftoverItemPoints*(items%2))
 
 	// If the trimmed length of the item description is a multiple of 3,
 	// multiple the prices by 0.2 and round up to the nearest integer.
+	var descriptions int
 	for _, item := range receipt.Items {
-		if len(strings.TrimSpace(item.Description))%3 != 0 {
+		n := len(rs.normalizeDescription(item.Description))
+		if n < rs.MinDescriptionLength || n%3 != 0 {
 			continue
 		}
 
@@ -115,27 +310,76 @@ func CalculatePoints(receipt *Receipt) int {
 		// division we divide by 5 instead of multiply by 0.2 and roll in the
 		// divide by 100 to convert the cents to points, leaving us with divide
 		// by 500.
-		points += item.Price / 500
+		descriptions += item.Price / 500
 
 		// Account for the round up for the truncated integer division by
 		// checking the remainder and tacking on an extra point if necessary
 		// below.
 		if item.Price%500 > 0 {
-			points++
+			descriptions++
 		}
 	}
+	award("itemDescription", descriptions)
 
 	// 6 points if the day in the purchase date is odd.
+	var day int
 	if receipt.Purchased.Day()%2 != 0 {
-		points += 6
+		day = 6
 	}
+	award("oddPurchaseDay", day)
 
 	// 10 points if the time of purchase is after 2:00pm and before 4:00pm.
+	var afternoon int
 	if hour := receipt.Purchased.Hour(); hour >= 14 && hour < 16 {
-		points += 10
+		afternoon = 10
 	}
+	award("afternoonPurchase", afternoon)
 
-	return points
+	// Bonus points if the purchase was made on one of the bonus weekdays, if
+	// so configured.
+	if len(rs.BonusWeekdays) > 0 && rs.WeekdayBonus > 0 {
+		var weekday int
+		if slices.Contains(rs.BonusWeekdays, receipt.Purchased.Weekday()) {
+			weekday = rs.WeekdayBonus
+		}
+		award("weekdayBonus", weekday)
+	}
+
+	var points int
+	for _, rule := range breakdown {
+		points += rule.Points
+	}
+
+	// No points for receipts with no items if so configured, offsetting the
+	// points of the rules independent of items.
+	if len(receipt.Items) == 0 && rs.EmptyReceipts == EmptyReceiptsZero {
+		award("emptyReceipt", -points)
+		points = 0
+	}
+
+	// Multiply the points if so configured, rounding half up, recording the
+	// points added by the multiplier so that the breakdown sums to the total.
+	if m := rs.PointsMultiplier; m.Numerator != m.Denominator && m.Denominator > 0 {
+		multiplied := (points*m.Numerator + m.Denominator/2) / m.Denominator
+		award("pointsMultiplier", multiplied-points)
+		points = multiplied
+	}
+
+	// Bonus points for the first receipt of a new customer, if so configured,
+	// which are not multiplied.
+	if receipt.NewCustomer && rs.NewCustomerBonus > 0 {
+		award("newCustomerBonus", rs.NewCustomerBonus)
+		points += rs.NewCustomerBonus
+	}
+
+	// Clamp the points to the maximum, including any bonuses, if so
+	// configured, recording the points removed by the cap.
+	if capped, ok := rs.capPoints(points); ok {
+		award(pointsCapRule, capped-points)
+		points = capped
+	}
+
+	return points, breakdown
 }
 
 // genUUID generates a UUIDv4.
@@ -0,0 +1,542 @@
+package fetch
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+	"unicode"
+)
+
+func TestCollapseDescriptionWhitespace(tt *testing.T) {
+	collapse := DefaultRuleset()
+	collapse.CollapseDescriptionWhitespace = true
+
+	for _, tc := range []struct {
+		name        string
+		ruleset     *Ruleset
+		description string
+		points      int
+	}{
+		{
+			name:        "default single space",
+			ruleset:     DefaultRuleset(),
+			description: "Mountain Dew",
+			points:      2,
+		},
+		{
+			name:        "default double space",
+			ruleset:     DefaultRuleset(),
+			description: "Mountain  Dew",
+			points:      0,
+		},
+		{
+			name:        "collapse single space",
+			ruleset:     collapse,
+			description: "Mountain Dew",
+			points:      2,
+		},
+		{
+			name:        "collapse double space",
+			ruleset:     collapse,
+			description: "Mountain  Dew",
+			points:      2,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				// Even day, morning purchase and a total that is not a
+				// multiple of 0.25 so that only the item description rule
+				// awards points.
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Items: []ReceiptItem{
+					{Description: tc.description, Price: 649},
+				},
+				Total: 649,
+			}
+
+			if points := tc.ruleset.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestNormalizeDescriptions(tt *testing.T) {
+	nfc := DefaultRuleset()
+	nfc.NormalizeDescriptions = NormalizeDescriptionsNFC
+
+	nfkc := DefaultRuleset()
+	nfkc.NormalizeDescriptions = NormalizeDescriptionsNFKC
+
+	for _, tc := range []struct {
+		name        string
+		ruleset     *Ruleset
+		description string
+		points      int
+	}{
+		{
+			name:        "default composed",
+			ruleset:     DefaultRuleset(),
+			description: "Caf\u00e9s",
+			points:      2,
+		},
+		{
+			name:        "default decomposed",
+			ruleset:     DefaultRuleset(),
+			description: "Cafe\u0301s",
+			points:      0,
+		},
+		{
+			name:        "nfc composed",
+			ruleset:     nfc,
+			description: "Caf\u00e9s",
+			points:      2,
+		},
+		{
+			name:        "nfc decomposed",
+			ruleset:     nfc,
+			description: "Cafe\u0301s",
+			points:      2,
+		},
+		{
+			name:        "nfc ligature",
+			ruleset:     nfc,
+			description: "\ufb01shes",
+			points:      0,
+		},
+		{
+			name:        "nfkc decomposed",
+			ruleset:     nfkc,
+			description: "Cafe\u0301s",
+			points:      2,
+		},
+		{
+			name:        "nfkc ligature",
+			ruleset:     nfkc,
+			description: "\ufb01shes",
+			points:      2,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				// Even day, morning purchase and a total that is not a
+				// multiple of 0.25 so that only the item description rule
+				// awards points.
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Items: []ReceiptItem{
+					{Description: tc.description, Price: 649},
+				},
+				Total: 649,
+			}
+
+			if points := tc.ruleset.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestLeftoverItemPoints(tt *testing.T) {
+	rs := DefaultRuleset()
+	rs.LeftoverItemPoints = 2
+
+	for _, tc := range []struct {
+		name   string
+		items  int
+		points int
+	}{
+		{name: "two items", items: 2, points: 5},
+		{name: "three items", items: 3, points: 7},
+		{name: "four items", items: 4, points: 10},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     649,
+			}
+
+			// Descriptions with a length that is not a multiple of 3 so
+			// that only the item count rules award points.
+			for i := 0; i < tc.items; i++ {
+				receipt.Items = append(receipt.Items, ReceiptItem{Description: "Gatorade", Price: 100})
+			}
+
+			if points := rs.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestWeekdayBonus(tt *testing.T) {
+	rs := DefaultRuleset()
+	rs.WeekdayBonus = 20
+	rs.BonusWeekdays = []time.Weekday{time.Saturday}
+
+	for _, tc := range []struct {
+		name      string
+		purchased time.Time
+		points    int
+	}{
+		// Purchase dates with an even day and a total that is not a multiple
+		// of 0.25 so that only the weekday bonus awards points.
+		{name: "friday", purchased: time.Date(2022, 1, 14, 8, 0, 0, 0, time.UTC), points: 0},
+		{name: "saturday", purchased: time.Date(2022, 1, 8, 8, 0, 0, 0, time.UTC), points: 20},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				Purchased: tc.purchased,
+				Total:     649,
+			}
+
+			if points := rs.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+
+			if points := DefaultRuleset().CalculatePoints(receipt); points != 0 {
+				t.Fatalf("default ruleset receipt points do not match, got %d, want 0", points)
+			}
+		})
+	}
+}
+
+func TestRetailerBonuses(tt *testing.T) {
+	rs := DefaultRuleset()
+	rs.RetailerBonuses = map[string]int{"Target": 100}
+
+	for _, tc := range []struct {
+		retailer string
+		points   int
+	}{
+		{retailer: "Target", points: 106},
+		{retailer: "target", points: 106},
+		{retailer: " TARGET ", points: 106},
+		{retailer: "Walmart", points: 7},
+	} {
+		tt.Run(tc.retailer, func(t *testing.T) {
+			// A purchase date with an even day and a total that is not a
+			// multiple of 0.25 so that only the retailer rules award points.
+			receipt := &Receipt{
+				Retailer:  tc.retailer,
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     649,
+			}
+
+			if points := rs.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestExactTotalRules(tt *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		total    int
+		discount Discount
+		rounded  int
+		exact    int
+	}{
+		{name: "no discount", total: 2000, rounded: 75, exact: 75},
+		{name: "exact percent", total: 2000, discount: Discount{Percent: 10}, rounded: 75, exact: 75},
+		// 7% off 0.27 is 0.2511, which rounds to the quarter 0.25.
+		{name: "rounded to quarter", total: 27, discount: Discount{Percent: 7}, rounded: 25, exact: 0},
+		// 1% off 1.01 is 0.9999, which rounds to the round dollar 1.00.
+		{name: "rounded to dollar", total: 101, discount: Discount{Percent: 1}, rounded: 75, exact: 0},
+		{name: "fixed amount", total: 2000, discount: Discount{Amount: 150}, rounded: 25, exact: 25},
+		{name: "full discount", total: 2000, discount: Discount{Percent: 100}, rounded: 75, exact: 75},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     tc.total,
+				Discount:  tc.discount,
+			}
+
+			rs := DefaultRuleset()
+			if points, _ := rs.CalculatePointsWithBreakdown(receipt); points != tc.rounded {
+				t.Fatalf("rounded points do not match, got %d, want %d", points, tc.rounded)
+			}
+
+			rs.ExactTotalRules = true
+			if points, _ := rs.CalculatePointsWithBreakdown(receipt); points != tc.exact {
+				t.Fatalf("exact points do not match, got %d, want %d", points, tc.exact)
+			}
+		})
+	}
+}
+
+func TestPointsMultiplier(tt *testing.T) {
+	// A receipt at Target with a round dollar total on an odd day earns 6 +
+	// 50 + 25 + 6 points before the multiplier.
+	receipt := &Receipt{
+		Retailer:  "Target",
+		Purchased: time.Date(2022, 1, 1, 8, 0, 0, 0, time.UTC),
+		Total:     100,
+	}
+
+	for _, tc := range []struct {
+		name       string
+		multiplier Multiplier
+		points     int
+	}{
+		{name: "default", multiplier: Multiplier{Numerator: 1, Denominator: 1}, points: 87},
+		{name: "double", multiplier: Multiplier{Numerator: 2, Denominator: 1}, points: 174},
+		{name: "one and a half rounds half up", multiplier: Multiplier{Numerator: 3, Denominator: 2}, points: 131},
+		{name: "zero", multiplier: Multiplier{Numerator: 0, Denominator: 1}, points: 0},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rs := DefaultRuleset()
+			rs.PointsMultiplier = tc.multiplier
+
+			points, breakdown := rs.CalculatePointsWithBreakdown(receipt)
+			if points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+
+			var sum int
+			for _, rule := range breakdown {
+				sum += rule.Points
+			}
+			if sum != points {
+				t.Fatalf("breakdown sum does not match, got %d, want %d", sum, points)
+			}
+		})
+	}
+}
+
+func TestRetailerCharacters(tt *testing.T) {
+	nonWhitespace := DefaultRuleset()
+	nonWhitespace.RetailerCharacters = RetailerNonWhitespace
+
+	upper := DefaultRuleset()
+	upper.RetailerCharacterFunc = unicode.IsUpper
+
+	for _, tc := range []struct {
+		name    string
+		ruleset *Ruleset
+		points  int
+	}{
+		{name: "alphanumeric", ruleset: DefaultRuleset(), points: 14},
+		{name: "non-whitespace", ruleset: nonWhitespace, points: 15},
+		{name: "custom predicate", ruleset: upper, points: 4},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				Retailer:  "M&M Corner Market",
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     649,
+			}
+
+			if points := tc.ruleset.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestMinDescriptionLength(tt *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		min         int
+		description string
+		points      int
+	}{
+		{name: "empty without minimum", min: 0, description: "", points: 2},
+		{name: "empty under minimum", min: 3, description: "", points: 0},
+		{name: "two characters under minimum", min: 3, description: "Ab", points: 0},
+		{name: "three characters at minimum", min: 3, description: "Gum", points: 2},
+		{name: "three characters under minimum", min: 4, description: "Gum", points: 0},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rs := DefaultRuleset()
+			rs.MinDescriptionLength = tc.min
+
+			receipt := &Receipt{
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Items: []ReceiptItem{
+					{Description: tc.description, Price: 649},
+				},
+				Total: 649,
+			}
+
+			if points := rs.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestCalculatePointsScored(t *testing.T) {
+	receipt := &Receipt{
+		Retailer:  "Target",
+		Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+		Total:     649,
+	}
+
+	// A receipt manually adjusted to zero points is not recalculated.
+	receipt.SetPoints(0, "fraud", time.Now())
+
+	if points := CalculatePoints(receipt); points != 0 {
+		t.Fatalf("scored receipt points do not match, got %d, want 0", points)
+	}
+}
+
+func TestExclusiveTotalRules(tt *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		exclusive bool
+		total     int
+		points    int
+	}{
+		{name: "round dollar additive", exclusive: false, total: 300, points: 75},
+		{name: "round dollar exclusive", exclusive: true, total: 300, points: 50},
+		{name: "quarter additive", exclusive: false, total: 325, points: 25},
+		{name: "quarter exclusive", exclusive: true, total: 325, points: 25},
+		{name: "neither exclusive", exclusive: true, total: 301, points: 0},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			rs := DefaultRuleset()
+			rs.ExclusiveTotalRules = tc.exclusive
+
+			receipt := &Receipt{
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     tc.total,
+			}
+
+			if points := rs.CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+func TestDiscount(tt *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		total    int
+		discount Discount
+		want     int
+		points   int
+	}{
+		{name: "no discount", total: 2000, want: 2000, points: 75},
+		{name: "ten percent", total: 2000, discount: Discount{Percent: 10}, want: 1800, points: 75},
+		{name: "fixed amount", total: 2000, discount: Discount{Amount: 150}, want: 1850, points: 25},
+		{name: "percent rounds half up", total: 10, discount: Discount{Percent: 15}, want: 8, points: 0},
+		{name: "percent rounds down", total: 2005, discount: Discount{Percent: 5}, want: 1905, points: 0},
+		{name: "full discount", total: 2000, discount: Discount{Percent: 100}, want: 0, points: 75},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			receipt := &Receipt{
+				Purchased: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+				Total:     tc.total,
+				Discount:  tc.discount,
+			}
+
+			if got := receipt.DiscountedTotal(); got != tc.want {
+				t.Fatalf("discounted total does not match, got %d, want %d", got, tc.want)
+			}
+
+			if points := CalculatePoints(receipt); points != tc.points {
+				t.Fatalf("receipt points do not match, got %d, want %d", points, tc.points)
+			}
+		})
+	}
+}
+
+// quickReceipt is a randomly generated receipt for property-based tests.
+type quickReceipt struct {
+	receipt *Receipt
+	// item is an additional item which may be added to the receipt.
+	item ReceiptItem
+}
+
+// Generate implements [quick.Generator].
+func (quickReceipt) Generate(r *rand.Rand, size int) reflect.Value {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789 &-"
+
+	text := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = chars[r.Intn(len(chars))]
+		}
+		return string(b)
+	}
+	item := func() ReceiptItem {
+		return ReceiptItem{Description: text(r.Intn(size + 1)), Price: r.Intn(10000)}
+	}
+
+	receipt := &Receipt{
+		Retailer:  text(r.Intn(size + 1)),
+		Purchased: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(r.Intn(365*24*60)) * time.Minute),
+		Total:     r.Intn(100000),
+	}
+	for n := r.Intn(8); n > 0; n-- {
+		receipt.Items = append(receipt.Items, item())
+	}
+
+	return reflect.ValueOf(quickReceipt{receipt: receipt, item: item()})
+}
+
+// TestCalculatePointsProperties verifies properties of the points calculation
+// which should hold for any receipt.
+//
+// Adding an item never decreases the points under the default ruleset, as
+// the item rules are additive and the other rules are independent of the
+// items. Rulesets may intentionally violate this: with LeftoverItemPoints
+// greater than ItemPairPoints the points of an odd number of items exceed
+// those of the next even number. The total rules are not monotonic in the
+// total, e.g. 1.00 earns the round dollar points but 1.01 does not.
+func TestCalculatePointsProperties(tt *testing.T) {
+	rulesets := map[string]*Ruleset{
+		"default": DefaultRuleset(),
+	}
+
+	multiplied := DefaultRuleset()
+	multiplied.PointsMultiplier = Multiplier{Numerator: 3, Denominator: 2}
+	multiplied.MaxPointsPerReceipt = 500
+	rulesets["multiplied and capped"] = multiplied
+
+	zero := DefaultRuleset()
+	zero.EmptyReceipts = EmptyReceiptsZero
+	rulesets["zero empty receipts"] = zero
+
+	for name, rs := range rulesets {
+		tt.Run(name+" deterministic", func(t *testing.T) {
+			deterministic := func(qr quickReceipt) bool {
+				return rs.CalculatePoints(qr.receipt) == rs.CalculatePoints(qr.receipt.Clone())
+			}
+
+			if err := quick.Check(deterministic, nil); err != nil {
+				t.Fatalf("points are not deterministic, %v", err)
+			}
+		})
+
+		tt.Run(name+" monotonic in items", func(t *testing.T) {
+			monotonic := func(qr quickReceipt) bool {
+				before := rs.CalculatePoints(qr.receipt)
+
+				added := qr.receipt.Clone()
+				added.Items = append(added.Items, qr.item)
+
+				return rs.CalculatePoints(added) >= before
+			}
+
+			if err := quick.Check(monotonic, nil); err != nil {
+				t.Fatalf("points decreased after adding an item, %v", err)
+			}
+		})
+
+		tt.Run(name+" non-negative", func(t *testing.T) {
+			nonNegative := func(qr quickReceipt) bool {
+				return rs.CalculatePoints(qr.receipt) >= 0
+			}
+
+			if err := quick.Check(nonNegative, nil); err != nil {
+				t.Fatalf("points are negative, %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxReceiptURLSize is the maximum size of a receipt fetched from a
+	// receipt URL.
+	maxReceiptURLSize = 1 << 20
+	// receiptURLTimeout is the maximum duration of fetching a receipt from a
+	// receipt URL.
+	receiptURLTimeout = 5 * time.Second
+)
+
+// WithReceiptURLHosts enables processing receipts by reference, where the
+// [ProcessReceipt] endpoint fetches the receipt JSON from the `receiptUrl` of
+// the request. Only receipt URLs with one of the given hosts are fetched,
+// guarding against server-side request forgery. By default receipt URLs are
+// not supported.
+//
+// A host matches `https` receipt URLs on the default port only, e.g.
+// "receipts.example.com". Other schemes and ports must be allowed explicitly
+// by giving the host as an origin, e.g. "http://localhost:8080".
+//
+// WithReceiptURLHosts panics if a host is not a valid host or origin.
+func WithReceiptURLHosts(hosts ...string) Option {
+	origins := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		origin := host
+		if !strings.Contains(origin, "://") {
+			origin = "https://" + origin
+		}
+
+		u, err := url.Parse(origin)
+		if err == nil && (u.Scheme != "https" && u.Scheme != "http" || u.Hostname() == "" || u.User != nil || u.Path != "" && u.Path != "/" || u.RawQuery != "") {
+			err = errors.New("invalid origin")
+		}
+		if err != nil {
+			panic(fmt.Sprintf("fetch: WithReceiptURLHosts host %q must be a host or an http or https origin", host))
+		}
+
+		origins = append(origins, receiptURLOrigin(u))
+	}
+
+	return func(api *API) {
+		api.receiptURLOrigins = origins
+	}
+}
+
+// receiptURLOrigin returns the origin of the URL, its scheme, host, and port,
+// with the default port of the scheme if none is given, e.g.
+// "https://receipts.example.com:443".
+func receiptURLOrigin(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	return u.Scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// errReceiptURL is an error with a receipt URL of a request, as opposed to an
+// error fetching the receipt from the URL.
+type errReceiptURL struct {
+	msg string
+}
+
+func (e *errReceiptURL) Error() string {
+	return e.msg
+}
+
+// checkReceiptURL returns an error if the receipt URL may not be fetched.
+func (api *API) checkReceiptURL(u *url.URL) error {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return &errReceiptURL{msg: fmt.Sprintf("receiptUrl scheme %q must be 'http' or 'https'", u.Scheme)}
+	}
+	if !slices.Contains(api.receiptURLOrigins, receiptURLOrigin(u)) {
+		return &errReceiptURL{msg: fmt.Sprintf("receiptUrl origin %q is not allowed", u.Scheme+"://"+u.Host)}
+	}
+
+	return nil
+}
+
+// fetchReceipt fetches the receipt JSON from the receipt URL into prreq,
+// following redirects only to allowed hosts.
+func (api *API) fetchReceipt(ctx context.Context, receiptURL string, prreq *ProcessReceiptRequest) error {
+	if len(api.receiptURLOrigins) == 0 {
+		return &errReceiptURL{msg: "receiptUrl is not supported"}
+	}
+
+	u, err := url.Parse(receiptURL)
+	if err != nil {
+		return &errReceiptURL{msg: fmt.Sprintf("invalid receiptUrl %q", receiptURL)}
+	}
+	if err := api.checkReceiptURL(u); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, receiptURLTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request, %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return api.checkReceiptURL(req.URL)
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxReceiptURLSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read receipt, %w", err)
+	}
+	if len(b) > maxReceiptURLSize {
+		return fmt.Errorf("receipt exceeds maximum size of %d bytes", maxReceiptURLSize)
+	}
+
+	if err := api.decodeReceipt(bytes.NewReader(b), prreq); err != nil {
+		return fmt.Errorf("failed to parse receipt, %w", err)
+	}
+
+	return nil
+}
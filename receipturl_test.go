@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReceiptURLHosts(tt *testing.T) {
+	receipt, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(receipt)
+	}))
+	tt.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		tt.Fatalf("failed to parse server URL, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		hosts  []string
+		status int
+	}{
+		{name: "origin", hosts: []string{srv.URL}, status: http.StatusOK},
+		{name: "origin with path", hosts: []string{srv.URL + "/"}, status: http.StatusOK},
+		{name: "host only allows https", hosts: []string{u.Host}, status: http.StatusBadRequest},
+		{name: "hostname only allows default port", hosts: []string{u.Hostname()}, status: http.StatusBadRequest},
+		{name: "other scheme", hosts: []string{"https://" + u.Host}, status: http.StatusBadRequest},
+		{name: "other port", hosts: []string{"http://" + u.Hostname() + ":1"}, status: http.StatusBadRequest},
+		{name: "disabled", status: http.StatusBadRequest},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(WithReceiptURLHosts(tc.hosts...))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(`{"receiptUrl": "`+srv.URL+`/receipt.json"}`))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+		})
+	}
+
+	for _, host := range []string{"", "ftp://example.com", "https://", "https://user@example.com", "https://example.com/receipts"} {
+		tt.Run("invalid "+host, func(t *testing.T) {
+			defer func() {
+				if v := recover(); v == nil {
+					t.Fatalf("WithReceiptURLHosts(%q) did not panic, want panic", host)
+				}
+			}()
+
+			WithReceiptURLHosts(host)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic recovers a panic from serving the request, logging the panic
+// and responding with `500 Internal Server Error` so that a single bad
+// request neither crashes the server nor leaves the client with a broken
+// response. It must be deferred.
+//
+// If the response has already been started the status can not be changed,
+// so the request is aborted with [http.ErrAbortHandler] rather than leaving
+// the client with a partial response that appears successful.
+func (api *API) recoverPanic(rw *trackingResponseWriter, req *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+
+	api.logger.ErrorContext(req.Context(), "panic serving request", "method", req.Method, "path", req.URL.Path, "panic", v, "stack", string(debug.Stack()))
+
+	if rw.wrote {
+		panic(http.ErrAbortHandler)
+	}
+
+	// Discard any headers set by the handler before panicking, e.g. a
+	// Content-Type for a response that was never written.
+	clear(rw.Header())
+
+	api.WriteError(rw, req, http.StatusInternalServerError, "internal server error")
+}
+
+// trackingResponseWriter is an [http.ResponseWriter] that records whether the
+// response has been started.
+type trackingResponseWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (rw *trackingResponseWriter) WriteHeader(status int) {
+	rw.ResponseWriter.WriteHeader(status)
+	rw.wrote = true
+}
+
+func (rw *trackingResponseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.wrote = true
+
+	return n, err
+}
+
+// Flush implements [http.Flusher] if the underlying response writer does, so
+// that streaming endpoints are unaffected.
+func (rw *trackingResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+		rw.wrote = true
+	}
+}
+
+// Unwrap returns the underlying response writer for [http.ResponseController].
+func (rw *trackingResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
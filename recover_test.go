@@ -0,0 +1,118 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// panicOnceWriter is an [http.ResponseWriter] that panics on the first write of
+// the response body, simulating a panic while encoding a response.
+type panicOnceWriter struct {
+	*httptest.ResponseRecorder
+	panicked bool
+}
+
+func (rw *panicOnceWriter) Write(b []byte) (int, error) {
+	if !rw.panicked {
+		rw.panicked = true
+		panic("encoding failure")
+	}
+
+	return rw.ResponseRecorder.Write(b)
+}
+
+func TestRecoverPanic(tt *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		rw   func(*httptest.ResponseRecorder) http.ResponseWriter
+	}{
+		{
+			name: "encoding panic",
+			rw: func(rec *httptest.ResponseRecorder) http.ResponseWriter {
+				return &panicOnceWriter{ResponseRecorder: rec}
+			},
+		},
+		{
+			name: "scoring panic",
+			opts: []Option{
+				WithAlwaysRecalculate(),
+				WithRuleset(&Ruleset{
+					RetailerCharacterFunc: func(rune) bool { panic("bad rule") },
+				}),
+			},
+			rw: func(rec *httptest.ResponseRecorder) http.ResponseWriter {
+				return rec
+			},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			api := NewAPI(append(tc.opts, WithErrorLog(log.New(&buf, "", 0)))...)
+
+			// Store the receipt directly since processing also calculates
+			// points.
+			receipt := &Receipt{ID: "7fb1377b-b223-49d9-a31a-5a02701dd310", Retailer: "Target"}
+			if err := api.store.Save(context.Background(), receipt); err != nil {
+				t.Fatalf("failed to save receipt, got %v, want no error", err)
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", receipt.ID), nil)
+
+			api.ServeHTTP(tc.rw(rec), req)
+
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status code does not match, got %d, want 500", rec.Code)
+			}
+
+			if got, want := rec.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Fatalf("content type does not match, got %q, want %q", got, want)
+			}
+
+			var got Error
+			dec := json.NewDecoder(rec.Body)
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("failed to parse error response, got %v, want no error", err)
+			}
+			if dec.More() {
+				t.Fatalf("error response has trailing data, want a single error")
+			}
+
+			if got, want := got.Message, "internal server error"; got != want {
+				t.Fatalf("error message does not match, got %q, want %q", got, want)
+			}
+
+			if !strings.Contains(buf.String(), `msg="panic serving request" method=GET`) {
+				t.Fatalf("error log does not match, got %q, want it to contain the panic", buf.String())
+			}
+		})
+	}
+}
+
+func TestRecoverPanicAfterWrite(t *testing.T) {
+	api := NewAPI(WithErrorLog(log.New(&bytes.Buffer{}, "", 0)))
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(rw, req)
+			panic("after write")
+		})
+	})
+
+	defer func() {
+		if got, want := recover(), http.ErrAbortHandler; got != want {
+			t.Fatalf("panic does not match, got %v, want %v", got, want)
+		}
+	}()
+
+	api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/version", nil))
+}
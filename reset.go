@@ -0,0 +1,83 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// WithReset enables the [Reset] endpoint of the handler returned by
+// [API.AdminHandler], which clears all stored receipts, e.g. to give
+// end-to-end tests sharing a server a clean slate between runs. The endpoint
+// is never served by the public API. If a token is configured with
+// [WithProfiling] requests must present it. It must not be enabled in
+// production.
+func WithReset() Option {
+	return func(api *API) {
+		api.reset = true
+	}
+}
+
+// ResetResponse is the response body that is returned from the [Reset]
+// endpoint.
+type ResetResponse struct {
+	// Cleared is the number of receipts removed from the store.
+	Cleared int `json:"cleared"`
+}
+
+// Reset is an [http.HandlerFunc] that removes all stored receipts, including
+// soft-deleted receipts, along with the content hashes recorded for them, and
+// resets the [ProcessStats] counters and the daily quotas of
+// [API.LimitDailyReceipts]. The endpoint is only available from the
+// [API.AdminHandler] when the API is configured with [WithReset].
+func (api *API) Reset(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	cleared, err := api.resetStore(req.Context())
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to reset store", "cleared", cleared, "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to reset store after clearing %d receipts", cleared)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&ResetResponse{
+		Cleared: cleared,
+	})
+}
+
+// resetStore removes all receipts from the store and clears the content
+// hashes, process counters, and daily quotas, returning the number of
+// receipts removed.
+func (api *API) resetStore(ctx context.Context) (int, error) {
+	var cleared int
+	err := api.locked(ctx, func(ctx context.Context) error {
+		cleared = 0
+
+		receipts, err := api.store.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range receipts {
+			if err := api.store.Delete(ctx, receipt.ID); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+			cleared++
+		}
+
+		clear(api.hashes)
+		clear(api.users)
+		api.processed.reset()
+		for _, quota := range api.quotas {
+			quota.reset()
+		}
+
+		return nil
+	})
+
+	return cleared, err
+}
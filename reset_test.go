@@ -0,0 +1,122 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	api := NewAPI(WithReset())
+	api.Use(api.LimitDailyReceipts(2))
+
+	ids := []string{
+		processReceipt(t, api, "testdata/simple-receipt.json"),
+		processReceipt(t, api, "testdata/morning-receipt.json"),
+	}
+	api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/receipts/process", strings.NewReader(`{`)))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/admin/reset", nil)
+
+	api.AdminHandler().ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to reset store, got %d status code, want 200", rw.Code)
+	}
+
+	var got ResetResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse reset response, got %v, want no error", err)
+	}
+
+	if got.Cleared != len(ids) {
+		t.Fatalf("cleared receipts do not match, got %d, want %d", got.Cleared, len(ids))
+	}
+
+	for _, id := range ids {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/receipts/"+id+"/points", nil)
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusNotFound {
+			t.Fatalf("receipt %q not cleared, got %d status code, want 404", id, rw.Code)
+		}
+	}
+
+	if len(api.hashes) != 0 {
+		t.Fatalf("content hashes not cleared, got %d, want none", len(api.hashes))
+	}
+
+	var failures int
+	api.processed.failures.Range(func(_, _ any) bool {
+		failures++
+		return true
+	})
+	if succeeded := api.processed.succeeded.Load(); succeeded != 0 || failures != 0 {
+		t.Fatalf("process counters not cleared, got %d succeeded and %d failure reasons, want none", succeeded, failures)
+	}
+
+	// The daily quota of 2 receipts was used up before the reset.
+	processReceipt(t, api, "testdata/simple-receipt.json")
+}
+
+func TestResetToken(t *testing.T) {
+	api := NewAPI(WithReset(), WithProfiling("secret"))
+
+	for _, tc := range []struct {
+		header string
+		status int
+	}{
+		{header: "", status: http.StatusUnauthorized},
+		{header: "Bearer wrong", status: http.StatusUnauthorized},
+		{header: "Bearer secret", status: http.StatusOK},
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/reset", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+
+		api.AdminHandler().ServeHTTP(rw, req)
+
+		if rw.Code != tc.status {
+			t.Fatalf("reset status with authorization %q does not match, got %d, want %d", tc.header, rw.Code, tc.status)
+		}
+	}
+}
+
+func TestResetDisabled(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []Option
+		handler func(api *API) http.Handler
+	}{
+		{name: "admin", handler: func(api *API) http.Handler { return api.AdminHandler() }},
+		{name: "public", opts: []Option{WithReset()}, handler: func(api *API) http.Handler { return api }},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			id := processReceipt(t, api, "testdata/simple-receipt.json")
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/admin/reset", nil)
+
+			tc.handler(api).ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusNotFound {
+				t.Fatalf("unavailable reset status does not match, got %d, want 404", rw.Code)
+			}
+
+			if _, err := api.store.Get(req.Context(), id); err != nil {
+				t.Fatalf("receipt cleared by unavailable reset, got %v, want no error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithStoreRetries configures the API to retry failed calls of the store, up
+// to attempts calls in total, so that transient failures of the store, e.g. a
+// locked database or a lost connection, do not immediately fail requests. The
+// first retry waits for backoff, doubling for each subsequent retry. Retries
+// stop early once the context of the request is done.
+//
+// The Create, Get, GetMany, List, and Save methods of the store are retried.
+// [ErrNotFound] and [ErrExists] are not transient and are never retried, so
+// stores must not report a failure to create a receipt which was created. By
+// default store calls are not retried.
+func WithStoreRetries(attempts int, backoff time.Duration) Option {
+	return func(api *API) {
+		api.storeAttempts = attempts
+		api.storeBackoff = backoff
+	}
+}
+
+// retryStore is a [Store] that retries failed calls to the embedded store,
+// see [WithStoreRetries].
+//
+// Calls made while holding the lock of the API are not retried, so that the
+// lock is not held while backing off. Their transient failures are instead
+// returned as a *transientError, retried by [API.locked] once the lock is
+// released.
+type retryStore struct {
+	Store
+	attempts int
+	backoff  time.Duration
+}
+
+func (s *retryStore) Create(ctx context.Context, receipt *Receipt) error {
+	return s.retry(ctx, func() error {
+		return s.Store.Create(ctx, receipt)
+	})
+}
+
+func (s *retryStore) Save(ctx context.Context, receipt *Receipt) error {
+	return s.retry(ctx, func() error {
+		return s.Store.Save(ctx, receipt)
+	})
+}
+
+func (s *retryStore) Get(ctx context.Context, id string) (*Receipt, error) {
+	var receipt *Receipt
+	err := s.retry(ctx, func() (err error) {
+		receipt, err = s.Store.Get(ctx, id)
+		return err
+	})
+
+	return receipt, err
+}
+
+func (s *retryStore) GetMany(ctx context.Context, ids []string) (map[string]*Receipt, error) {
+	var receipts map[string]*Receipt
+	err := s.retry(ctx, func() (err error) {
+		receipts, err = s.Store.GetMany(ctx, ids)
+		return err
+	})
+
+	return receipts, err
+}
+
+func (s *retryStore) List(ctx context.Context) ([]*Receipt, error) {
+	var receipts []*Receipt
+	err := s.retry(ctx, func() (err error) {
+		receipts, err = s.Store.List(ctx)
+		return err
+	})
+
+	return receipts, err
+}
+
+// retry calls fn with retries, unless the context is of a call made while
+// holding the lock of the API, in which case a transient failure is returned
+// as a *transientError.
+func (s *retryStore) retry(ctx context.Context, fn func() error) error {
+	if ctx.Value(lockedKey{}) != nil {
+		err := fn()
+		if transient(err) {
+			return &transientError{err: err}
+		}
+
+		return err
+	}
+
+	return retry(ctx, s.attempts, s.backoff, fn, transient)
+}
+
+// lockedKey is the context key marking store calls made while holding the
+// lock of the API.
+type lockedKey struct{}
+
+// transientError is a transient failure of a store call made while holding
+// the lock of the API, see [retryStore].
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// locked calls fn while holding the lock of the API, calling it again after
+// releasing the lock if it fails with a transient store error, see
+// [WithStoreRetries]. Since fn may be called more than once it must not
+// modify state outside the lock before it succeeds.
+func (api *API) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	lockedCtx := context.WithValue(ctx, lockedKey{}, true)
+
+	return retry(ctx, api.storeAttempts, api.storeBackoff, func() error {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+
+		return fn(lockedCtx)
+	}, func(err error) bool {
+		var terr *transientError
+		return errors.As(err, &terr)
+	})
+}
+
+// transient reports whether the error of a store call is transient.
+func transient(err error) bool {
+	return err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrExists)
+}
+
+// retry calls fn until it succeeds, fails with an error which is not
+// retryable, the attempts are exhausted, or the context is done, returning
+// the last error of fn. The first retry waits for backoff, doubling for each
+// subsequent retry.
+func retry(ctx context.Context, attempts int, backoff time.Duration, fn func() error, retryable func(error) bool) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable(err) || attempt >= attempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
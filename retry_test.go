@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyStore is a [Store] whose Create, Get, and Save each fail with a
+// transient error the configured number of times before delegating to the
+// embedded store. failed, if set, is called after each failure.
+type flakyStore struct {
+	Store
+	createFailures int
+	getFailures    int
+	saveFailures   int
+	failed         func()
+}
+
+var errFlaky = errors.New("database is locked")
+
+func (s *flakyStore) Create(ctx context.Context, receipt *Receipt) error {
+	if s.createFailures > 0 {
+		s.createFailures--
+		s.fail()
+		return errFlaky
+	}
+
+	return s.Store.Create(ctx, receipt)
+}
+
+func (s *flakyStore) Get(ctx context.Context, id string) (*Receipt, error) {
+	if s.getFailures > 0 {
+		s.getFailures--
+		s.fail()
+		return nil, errFlaky
+	}
+
+	return s.Store.Get(ctx, id)
+}
+
+func (s *flakyStore) Save(ctx context.Context, receipt *Receipt) error {
+	if s.saveFailures > 0 {
+		s.saveFailures--
+		s.fail()
+		return errFlaky
+	}
+
+	return s.Store.Save(ctx, receipt)
+}
+
+func (s *flakyStore) fail() {
+	if s.failed != nil {
+		s.failed()
+	}
+}
+
+func TestStoreRetries(tt *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		attempts int
+		failures int
+		status   int
+	}{
+		{name: "no retries", attempts: 0, failures: 1, status: http.StatusInternalServerError},
+		{name: "succeeds after retries", attempts: 3, failures: 2, status: http.StatusOK},
+		{name: "attempts exhausted", attempts: 2, failures: 2, status: http.StatusInternalServerError},
+		{name: "always fails", attempts: 3, failures: 1 << 30, status: http.StatusInternalServerError},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := &flakyStore{Store: NewMemoryStore()}
+			api := NewAPI(WithStore(store), WithStoreRetries(tc.attempts, time.Millisecond))
+
+			id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+			store.getFailures = tc.failures
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", fmt.Sprintf("/receipts/%s/points", id), nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("get points status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			if got := getPoints(t, api, id); got != 28 {
+				t.Fatalf("points do not match, got %d, want 28", got)
+			}
+		})
+	}
+}
+
+func TestStoreRetriesSave(t *testing.T) {
+	store := &flakyStore{Store: NewMemoryStore()}
+	api := NewAPI(WithStore(store), WithStoreRetries(3, time.Millisecond))
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	// The read and the save of the adjustment each fail once.
+	store.getFailures = 1
+	store.saveFailures = 1
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/receipts/%s/points", id), strings.NewReader(`{"points": 100, "reason": "customer service"}`))
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("adjust points status does not match, got %d, want 200", rw.Code)
+	}
+
+	if got := getPoints(t, api, id); got != 100 {
+		t.Fatalf("points do not match, got %d, want 100", got)
+	}
+}
+
+func TestStoreRetriesContext(t *testing.T) {
+	store := &flakyStore{Store: NewMemoryStore(), getFailures: 1 << 30}
+	retry := &retryStore{Store: store, attempts: 1 << 30, backoff: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := retry.Get(ctx, "id"); !errors.Is(err, errFlaky) {
+		t.Fatalf("get error does not match, got %v, want %v", err, errFlaky)
+	}
+}
+
+func TestStoreRetriesCreate(t *testing.T) {
+	rs := DefaultRuleset()
+	rs.NewCustomerBonus = 100
+
+	store := &flakyStore{Store: NewMemoryStore(), createFailures: 2}
+	api := NewAPI(WithStore(store), WithRuleset(rs), WithStoreRetries(3, time.Millisecond))
+
+	body, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		t.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	var prreq ProcessReceiptRequest
+	if err := json.Unmarshal(body, &prreq); err != nil {
+		t.Fatalf("failed to parse receipt, got %v, want no error", err)
+	}
+	prreq.UserID = "alice"
+
+	id := processReceiptBody(t, api, receiptJSON(t, &prreq))
+
+	// The new customer bonus is awarded once across retries.
+	if got := getPoints(t, api, id); got != 128 {
+		t.Fatalf("points do not match, got %d, want 128", got)
+	}
+}
+
+func TestStoreRetriesUnlocked(t *testing.T) {
+	store := &flakyStore{Store: NewMemoryStore()}
+	api := NewAPI(WithStore(store), WithStoreRetries(2, time.Hour))
+
+	id := processReceipt(t, api, "testdata/readme-target-receipt.json")
+
+	failed := make(chan struct{})
+	store.saveFailures = 1
+	store.failed = func() { close(failed) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		_, err := api.modify(ctx, id, func(receipt *Receipt) {})
+		done <- err
+	}()
+
+	<-failed
+
+	// The lock is released while backing off before retrying the save.
+	deadline := time.Now().Add(time.Second)
+	for !api.mu.TryLock() {
+		if time.Now().After(deadline) {
+			t.Fatalf("lock held while backing off")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	api.mu.Unlock()
+
+	cancel()
+
+	if err := <-done; !errors.Is(err, errFlaky) {
+		t.Fatalf("modify error does not match, got %v, want %v", err, errFlaky)
+	}
+}
@@ -0,0 +1,382 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Character classes counted by the retailer name rule, see
+// [Ruleset.RetailerCharacters].
+const (
+	// RetailerAlphanumeric counts letters and digits.
+	RetailerAlphanumeric = "alphanumeric"
+	// RetailerNonWhitespace counts all characters other than whitespace.
+	RetailerNonWhitespace = "nonWhitespace"
+)
+
+// Treatments of receipts with no items, see [Ruleset.EmptyReceipts].
+const (
+	// EmptyReceiptsScore scores receipts with no items as any other receipt,
+	// awarding the points of the rules independent of items.
+	EmptyReceiptsScore = "score"
+	// EmptyReceiptsZero awards no points to receipts with no items.
+	EmptyReceiptsZero = "zero"
+	// EmptyReceiptsReject rejects receipts with no items.
+	EmptyReceiptsReject = "reject"
+)
+
+// Treatments of items with a zero price, see [Ruleset.ZeroPriceItems].
+const (
+	// ZeroPriceItemsCount counts items with a zero price as any other item.
+	ZeroPriceItemsCount = "count"
+	// ZeroPriceItemsExclude excludes items with a zero price from the item
+	// count rules.
+	ZeroPriceItemsExclude = "exclude"
+	// ZeroPriceItemsReject rejects receipts with items with a zero price.
+	ZeroPriceItemsReject = "reject"
+)
+
+// Unicode normalization forms of item descriptions, see
+// [Ruleset.NormalizeDescriptions].
+const (
+	// NormalizeDescriptionsNFC normalizes descriptions to the canonical
+	// composition, e.g. "e" and a combining acute accent to "é".
+	NormalizeDescriptionsNFC = "NFC"
+	// NormalizeDescriptionsNFKC normalizes descriptions to the compatibility
+	// composition, which also folds compatibility characters, e.g. the
+	// ligature "ﬁ" to "fi".
+	NormalizeDescriptionsNFKC = "NFKC"
+)
+
+// Multiplier is a rational multiplier of points, kept as an integer numerator
+// and denominator so that all points math is integer math, e.g. 3/2 for one
+// and a half times the points.
+type Multiplier struct {
+	// Numerator is the numerator of the multiplier.
+	Numerator int `json:"numerator"`
+	// Denominator is the denominator of the multiplier, which must be
+	// positive.
+	Denominator int `json:"denominator"`
+}
+
+// Ruleset configures the rules used to calculate the number of Fetch rewards
+// points a receipt is worth. The zero value is not valid, use
+// [DefaultRuleset] as the basis for custom rulesets.
+type Ruleset struct {
+	// RetailerCharacters is the class of characters in the retailer name
+	// which are each awarded a point, one of [RetailerAlphanumeric] or
+	// [RetailerNonWhitespace].
+	RetailerCharacters string `json:"retailerCharacters"`
+	// RetailerCharacterFunc is a custom predicate for the characters in the
+	// retailer name which are each awarded a point. If set, it takes
+	// precedence over RetailerCharacters.
+	RetailerCharacterFunc func(r rune) bool `json:"-"`
+	// ItemPairPoints are the points awarded for every two items on the
+	// receipt.
+	ItemPairPoints int `json:"itemPairPoints"`
+	// LeftoverItemPoints are the points awarded for the leftover item when
+	// the receipt has an odd number of items.
+	LeftoverItemPoints int `json:"leftoverItemPoints"`
+	// MinDescriptionLength is the minimum trimmed length of an item
+	// description for the item description rule to apply, preventing empty
+	// or very short descriptions from earning points.
+	MinDescriptionLength int `json:"minDescriptionLength"`
+	// CollapseDescriptionWhitespace collapses internal runs of whitespace in
+	// item descriptions to a single space before measuring the trimmed
+	// length, e.g. "Mountain  Dew" is measured as "Mountain Dew".
+	CollapseDescriptionWhitespace bool `json:"collapseDescriptionWhitespace"`
+	// NormalizeDescriptions is the Unicode normalization form item
+	// descriptions are normalized to before measuring the trimmed length,
+	// one of [NormalizeDescriptionsNFC] or [NormalizeDescriptionsNFKC], so
+	// that e.g. "é" as a single code point or as "e" and a combining accent
+	// are measured the same. Empty does not normalize descriptions.
+	NormalizeDescriptions string `json:"normalizeDescriptions"`
+	// ExclusiveTotalRules makes the round dollar and multiple of 0.25 total
+	// rules mutually exclusive, awarding only the round dollar points to
+	// round dollar totals rather than both.
+	ExclusiveTotalRules bool `json:"exclusiveTotalRules"`
+	// ExactTotalRules applies the total rules to the exact total after any
+	// percentage discount, rather than the total rounded to the nearest cent,
+	// so that e.g. 25.11 cents is not rounded to a multiple of 0.25.
+	ExactTotalRules bool `json:"exactTotalRules"`
+	// EmptyReceipts is the treatment of receipts with no items, one of
+	// [EmptyReceiptsScore], [EmptyReceiptsZero], or [EmptyReceiptsReject].
+	// Defaults to [EmptyReceiptsScore] if empty.
+	EmptyReceipts string `json:"emptyReceipts"`
+	// ZeroPriceItems is the treatment of items with a zero price, which are
+	// suspicious, one of [ZeroPriceItemsCount], [ZeroPriceItemsExclude], or
+	// [ZeroPriceItemsReject]. Defaults to [ZeroPriceItemsCount] if empty.
+	ZeroPriceItems string `json:"zeroPriceItems"`
+	// WeekdayBonus are the bonus points awarded to receipts purchased on one
+	// of the BonusWeekdays, e.g. for a weekend promotion.
+	WeekdayBonus int `json:"weekdayBonus"`
+	// BonusWeekdays are the days of the week on which purchases are awarded
+	// the WeekdayBonus, in JSON as numbers from 0 for Sunday to 6 for
+	// Saturday.
+	BonusWeekdays []time.Weekday `json:"bonusWeekdays"`
+	// RetailerBonuses are the bonus points awarded to receipts from partner
+	// retailers, keyed by retailer name. Retailer names are matched ignoring
+	// case and leading and trailing whitespace, so names which differ only by
+	// case or whitespace are rejected. The bonuses must not be modified once
+	// the ruleset is used to calculate points.
+	RetailerBonuses map[string]int `json:"retailerBonuses"`
+	// PointsMultiplier multiplies the sum of the points awarded by the rules,
+	// e.g. 2/1 for a double points campaign. The multiplied points are
+	// rounded half up to the nearest point. Defaults to 1/1.
+	PointsMultiplier Multiplier `json:"pointsMultiplier"`
+	// MaxPointsPerReceipt caps the points awarded to a receipt, guarding
+	// against absurd scores from malformed or adversarial receipts. The cap
+	// applies to the points of the rules after all bonuses, including the
+	// NewCustomerBonus. Zero is unlimited.
+	MaxPointsPerReceipt int `json:"maxPointsPerReceipt"`
+	// NewCustomerBonus are the bonus points awarded by the [API] to the first
+	// processed receipt of a user, for receipts submitted with a user ID,
+	// marked as [Receipt.NewCustomer]. The bonus is not multiplied by the
+	// PointsMultiplier.
+	NewCustomerBonus int `json:"newCustomerBonus"`
+
+	// retailerBonuses are the RetailerBonuses keyed by normalized retailer
+	// name, built once on first use.
+	retailerBonuses     map[string]int
+	retailerBonusesOnce sync.Once
+}
+
+// DefaultRuleset returns the ruleset implementing the standard point rules.
+func DefaultRuleset() *Ruleset {
+	return &Ruleset{
+		RetailerCharacters: RetailerAlphanumeric,
+		ItemPairPoints:     5,
+		EmptyReceipts:      EmptyReceiptsScore,
+		ZeroPriceItems:     ZeroPriceItemsCount,
+		PointsMultiplier:   Multiplier{Numerator: 1, Denominator: 1},
+	}
+}
+
+// GetRuleset is an [http.HandlerFunc] that returns the [Ruleset] used by the
+// API to calculate points as JSON, in the format accepted by [LoadRuleset],
+// e.g. to audit the point values in effect. Custom predicates of the ruleset
+// are not included.
+func (api *API) GetRuleset(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(api.ruleset)
+}
+
+// LoadRuleset loads a ruleset from the JSON file at path, e.g. to tweak point
+// values without recompiling. Rules omitted from the file keep their
+// [DefaultRuleset] values. The loaded ruleset is validated, and files with
+// unknown rules are rejected to catch misspelled rules.
+func LoadRuleset(path string) (*Ruleset, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ruleset, %w", err)
+	}
+
+	rs := DefaultRuleset()
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(rs); err != nil {
+		return nil, fmt.Errorf("failed to parse ruleset %q, %w", path, err)
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ruleset %q, %w", path, err)
+	}
+
+	return rs, nil
+}
+
+// Validate checks that the ruleset has sane values, returning an error
+// describing the first invalid value.
+func (rs *Ruleset) Validate() error {
+	switch rs.RetailerCharacters {
+	case RetailerAlphanumeric, RetailerNonWhitespace:
+	default:
+		return fmt.Errorf("retailerCharacters must be one of %q or %q, got %q", RetailerAlphanumeric, RetailerNonWhitespace, rs.RetailerCharacters)
+	}
+
+	switch rs.EmptyReceipts {
+	case "", EmptyReceiptsScore, EmptyReceiptsZero, EmptyReceiptsReject:
+	default:
+		return fmt.Errorf("emptyReceipts must be one of %q, %q, or %q, got %q", EmptyReceiptsScore, EmptyReceiptsZero, EmptyReceiptsReject, rs.EmptyReceipts)
+	}
+
+	switch rs.ZeroPriceItems {
+	case "", ZeroPriceItemsCount, ZeroPriceItemsExclude, ZeroPriceItemsReject:
+	default:
+		return fmt.Errorf("zeroPriceItems must be one of %q, %q, or %q, got %q", ZeroPriceItemsCount, ZeroPriceItemsExclude, ZeroPriceItemsReject, rs.ZeroPriceItems)
+	}
+
+	switch rs.NormalizeDescriptions {
+	case "", NormalizeDescriptionsNFC, NormalizeDescriptionsNFKC:
+	default:
+		return fmt.Errorf("normalizeDescriptions must be empty, %q, or %q, got %q", NormalizeDescriptionsNFC, NormalizeDescriptionsNFKC, rs.NormalizeDescriptions)
+	}
+
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{name: "itemPairPoints", value: rs.ItemPairPoints},
+		{name: "leftoverItemPoints", value: rs.LeftoverItemPoints},
+		{name: "minDescriptionLength", value: rs.MinDescriptionLength},
+		{name: "weekdayBonus", value: rs.WeekdayBonus},
+		{name: "maxPointsPerReceipt", value: rs.MaxPointsPerReceipt},
+		{name: "newCustomerBonus", value: rs.NewCustomerBonus},
+	} {
+		if field.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", field.name, field.value)
+		}
+	}
+
+	if rs.PointsMultiplier.Numerator < 0 || rs.PointsMultiplier.Denominator < 1 {
+		return fmt.Errorf("pointsMultiplier must have a non-negative numerator and positive denominator, got %d/%d", rs.PointsMultiplier.Numerator, rs.PointsMultiplier.Denominator)
+	}
+
+	retailers := make(map[string]string, len(rs.RetailerBonuses))
+	for retailer, bonus := range rs.RetailerBonuses {
+		if bonus < 0 {
+			return fmt.Errorf("retailerBonuses of %q must not be negative, got %d", retailer, bonus)
+		}
+
+		key := retailerKey(retailer)
+		if other, ok := retailers[key]; ok {
+			return fmt.Errorf("retailerBonuses of %q and %q must not match the same retailer", other, retailer)
+		}
+		retailers[key] = retailer
+	}
+
+	for _, day := range rs.BonusWeekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("bonusWeekdays must be between 0 (Sunday) and 6 (Saturday), got %d", day)
+		}
+	}
+
+	return nil
+}
+
+// accept returns an error if the receipt is rejected by the ruleset.
+func (rs *Ruleset) accept(receipt *Receipt) error {
+	// Items which are empty, null, or omitted are all parsed as no items.
+	if len(receipt.Items) == 0 && rs.EmptyReceipts == EmptyReceiptsReject {
+		return invalidf("receipt has no items, at least one item is required")
+	}
+
+	if rs.ZeroPriceItems == ZeroPriceItemsReject {
+		for i, item := range receipt.Items {
+			if item.Price == 0 {
+				return invalidf("price of items[%d] %q must not be zero", i, item.Description)
+			}
+		}
+	}
+
+	return nil
+}
+
+// retailerCharacter reports whether the character in the retailer name is
+// awarded a point by the retailer name rule.
+func (rs *Ruleset) retailerCharacter(r rune) bool {
+	if rs.RetailerCharacterFunc != nil {
+		return rs.RetailerCharacterFunc(r)
+	}
+
+	if rs.RetailerCharacters == RetailerNonWhitespace {
+		return !unicode.IsSpace(r)
+	}
+
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// totalMultiples reports whether the total of the receipt after any discount
+// is a round dollar amount and whether it is a multiple of 0.25.
+func (rs *Ruleset) totalMultiples(receipt *Receipt) (dollar, quarter bool) {
+	if !rs.ExactTotalRules {
+		total := receipt.DiscountedTotal()
+		return total%100 == 0, total%25 == 0
+	}
+
+	total := receipt.exactDiscountedTotal()
+	multiple := func(cents int64) bool {
+		return new(big.Rat).Quo(total, new(big.Rat).SetInt64(cents)).IsInt()
+	}
+
+	return multiple(100), multiple(25)
+}
+
+// capPoints returns the points clamped to MaxPointsPerReceipt, reporting
+// whether they were clamped.
+func (rs *Ruleset) capPoints(points int) (int, bool) {
+	if rs.MaxPointsPerReceipt > 0 && points > rs.MaxPointsPerReceipt {
+		return rs.MaxPointsPerReceipt, true
+	}
+
+	return points, false
+}
+
+// countedItems returns the number of items on the receipt counted by the item
+// count rules.
+func (rs *Ruleset) countedItems(receipt *Receipt) int {
+	if rs.ZeroPriceItems != ZeroPriceItemsExclude {
+		return len(receipt.Items)
+	}
+
+	var n int
+	for _, item := range receipt.Items {
+		if item.Price != 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// retailerBonus returns the bonus points awarded to receipts from the
+// retailer, if any.
+func (rs *Ruleset) retailerBonus(retailer string) int {
+	rs.retailerBonusesOnce.Do(func() {
+		rs.retailerBonuses = make(map[string]int, len(rs.RetailerBonuses))
+		for name, bonus := range rs.RetailerBonuses {
+			rs.retailerBonuses[retailerKey(name)] = bonus
+		}
+	})
+
+	return rs.retailerBonuses[retailerKey(retailer)]
+}
+
+// retailerKey returns the retailer name as matched by the retailer bonus rule,
+// ignoring case and leading and trailing whitespace.
+func retailerKey(retailer string) string {
+	return strings.ToLower(strings.TrimSpace(retailer))
+}
+
+// normalizeDescription returns the item description as measured by the item
+// description rule.
+func (rs *Ruleset) normalizeDescription(description string) string {
+	switch rs.NormalizeDescriptions {
+	case NormalizeDescriptionsNFC:
+		description = norm.NFC.String(description)
+	case NormalizeDescriptionsNFKC:
+		description = norm.NFKC.String(description)
+	}
+
+	if rs.CollapseDescriptionWhitespace {
+		return collapseWhitespace(description)
+	}
+
+	return strings.TrimSpace(description)
+}
@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRuleset(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		json    string
+		want    func(rs *Ruleset) bool
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			json: `{"itemPairPoints": 10, "leftoverItemPoints": 2}`,
+			want: func(rs *Ruleset) bool {
+				return rs.ItemPairPoints == 10 && rs.LeftoverItemPoints == 2 && rs.RetailerCharacters == RetailerAlphanumeric
+			},
+		},
+		{
+			name:    "malformed",
+			json:    `{"itemPairPoints": 10`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown rule",
+			json:    `{"itemPairPoint": 10}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid rule",
+			json:    `{"itemPairPoints": -5}`,
+			wantErr: true,
+		},
+		{
+			name: "weekday bonus",
+			json: `{"weekdayBonus": 20, "bonusWeekdays": [0, 6]}`,
+			want: func(rs *Ruleset) bool {
+				return rs.WeekdayBonus == 20 && len(rs.BonusWeekdays) == 2
+			},
+		},
+		{
+			name:    "invalid points multiplier",
+			json:    `{"pointsMultiplier": {"numerator": 2, "denominator": 0}}`,
+			wantErr: true,
+		},
+		{
+			name: "normalize descriptions",
+			json: `{"normalizeDescriptions": "NFC"}`,
+			want: func(rs *Ruleset) bool {
+				return rs.NormalizeDescriptions == NormalizeDescriptionsNFC
+			},
+		},
+		{
+			name:    "invalid normalize descriptions",
+			json:    `{"normalizeDescriptions": "NFD"}`,
+			wantErr: true,
+		},
+		{
+			name: "retailer bonuses",
+			json: `{"retailerBonuses": {"Target": 10, "Walmart": 20}}`,
+			want: func(rs *Ruleset) bool {
+				return len(rs.RetailerBonuses) == 2
+			},
+		},
+		{
+			name:    "duplicate retailer bonuses",
+			json:    `{"retailerBonuses": {"Target": 10, "target ": 500}}`,
+			wantErr: true,
+		},
+		{
+			name: "default treatments",
+			json: `{"emptyReceipts": "", "zeroPriceItems": ""}`,
+			want: func(rs *Ruleset) bool {
+				return rs.EmptyReceipts == "" && rs.ZeroPriceItems == ""
+			},
+		},
+		{
+			name:    "invalid empty receipts",
+			json:    `{"emptyReceipts": "ignore"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid weekday",
+			json:    `{"weekdayBonus": 20, "bonusWeekdays": [7]}`,
+			wantErr: true,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "ruleset.json")
+			if err := os.WriteFile(path, []byte(tc.json), 0o644); err != nil {
+				t.Fatalf("failed to write ruleset file, got %v, want no error", err)
+			}
+
+			rs, err := LoadRuleset(path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("load ruleset error does not match, got %v, want error %v", err, tc.wantErr)
+			}
+
+			if tc.want != nil && !tc.want(rs) {
+				t.Fatalf("ruleset does not match, got %+v", rs)
+			}
+		})
+	}
+
+	tt.Run("missing", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := LoadRuleset(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+			t.Fatalf("load ruleset error does not match, got nil, want error")
+		}
+	})
+}
+
+func TestGetRuleset(tt *testing.T) {
+	custom := DefaultRuleset()
+	custom.ItemPairPoints = 10
+	custom.RetailerBonuses = map[string]int{"Target": 100}
+
+	for _, tc := range []struct {
+		name string
+		rs   *Ruleset
+	}{
+		{name: "default", rs: DefaultRuleset()},
+		{name: "custom", rs: custom},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			api := NewAPI(WithRuleset(tc.rs))
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/ruleset", nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to get ruleset, got %d status code, want 200", rw.Code)
+			}
+
+			var got Ruleset
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse ruleset response, got %v, want no error", err)
+			}
+
+			if !reflect.DeepEqual(&got, tc.rs) {
+				t.Fatalf("ruleset does not match, got %+v, want %+v", &got, tc.rs)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package fetch
+
+import (
+	"net/http"
+)
+
+// SimulatePointsRequest is the request body that is submitted to the
+// [SimulatePoints] endpoint.
+type SimulatePointsRequest struct {
+	// Receipt is the receipt to calculate the points of.
+	Receipt ProcessReceiptRequest `json:"receipt"`
+	// Ruleset is the ruleset to calculate the points under. Rules omitted
+	// from the ruleset keep their [DefaultRuleset] values.
+	Ruleset *Ruleset `json:"ruleset"`
+}
+
+// SimulatePoints is an [http.HandlerFunc] that calculates the point value of a
+// receipt under the supplied ruleset without storing the receipt, allowing
+// the effects of a ruleset to be previewed.
+func (api *API) SimulatePoints(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'POST'")
+		return
+	}
+
+	spreq := SimulatePointsRequest{
+		Ruleset: DefaultRuleset(),
+	}
+	if err := api.decode(req.Body, &spreq); err != nil {
+		api.WriteError(rw, req, http.StatusBadRequest, "failed to parse simulate points request, %v", err)
+		return
+	}
+
+	if spreq.Ruleset == nil {
+		spreq.Ruleset = DefaultRuleset()
+	}
+
+	if err := spreq.Ruleset.Validate(); err != nil {
+		api.WriteError(rw, req, http.StatusUnprocessableEntity, "invalid ruleset, %v", err)
+		return
+	}
+
+	receipt, _, err := api.parseReceipt(&spreq.Receipt)
+	if err == nil {
+		err = spreq.Ruleset.accept(receipt)
+	}
+	if err != nil {
+		api.WriteError(rw, req, receiptErrorStatus(err), "invalid simulate points request, %v", err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&GetPointsResponse{
+		Points: spreq.Ruleset.CalculatePoints(receipt),
+	})
+}
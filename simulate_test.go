@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSimulatePoints(tt *testing.T) {
+	api := NewAPI()
+
+	receipt, err := os.ReadFile("testdata/readme-target-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt file, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		ruleset string
+		status  int
+		points  int
+	}{
+		{
+			name:    "default ruleset",
+			ruleset: `{}`,
+			status:  http.StatusOK,
+			points:  28,
+		},
+		{
+			name:    "doubled item pair points",
+			ruleset: `{"itemPairPoints": 10}`,
+			status:  http.StatusOK,
+			points:  38,
+		},
+		{
+			name:    "leftover item points",
+			ruleset: `{"leftoverItemPoints": 3}`,
+			status:  http.StatusOK,
+			points:  31,
+		},
+		{
+			name:    "invalid ruleset",
+			ruleset: `{"itemPairPoints": -5}`,
+			status:  http.StatusUnprocessableEntity,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			body := `{"receipt": ` + string(receipt) + `, "ruleset": ` + tc.ruleset + `}`
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/simulate", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != tc.status {
+				t.Fatalf("simulate points status does not match, got %d, want %d", rw.Code, tc.status)
+			}
+
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var got GetPointsResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse points response, got %v, want no error", err)
+			}
+
+			if got.Points != tc.points {
+				t.Fatalf("simulated points do not match, got %d, want %d", got.Points, tc.points)
+			}
+		})
+	}
+
+	if n := countReceipts(tt, api); n != 0 {
+		tt.Fatalf("stored receipt count does not match, got %d, want 0", n)
+	}
+}
@@ -0,0 +1,158 @@
+package fetch
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// PointsStatsResponse is the response body that is returned from the
+// [PointsStats] endpoint. All statistics are zero if there are no receipts.
+type PointsStatsResponse struct {
+	// Count is the number of receipts.
+	Count int `json:"count"`
+	// Min is the fewest points assigned to a receipt.
+	Min int `json:"min"`
+	// Max is the most points assigned to a receipt.
+	Max int `json:"max"`
+	// Mean is the average points assigned to a receipt.
+	Mean float64 `json:"mean"`
+	// Median is the median points assigned to a receipt, the average of the
+	// two middle values for an even number of receipts.
+	Median float64 `json:"median"`
+}
+
+// PointsStats is an [http.HandlerFunc] that returns aggregate statistics of
+// the points assigned to all receipts, for a quick overview of the health of
+// the points calculation. Soft-deleted receipts are excluded.
+func (api *API) PointsStats(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	receipts, err := api.store.List(req.Context())
+	if err != nil {
+		api.logger.ErrorContext(req.Context(), "failed to list receipts", "error", err)
+		api.WriteError(rw, req, http.StatusInternalServerError, "failed to list receipts")
+		return
+	}
+
+	points := make([]int, 0, len(receipts))
+	for _, receipt := range receipts {
+		if receipt.Deleted {
+			continue
+		}
+
+		points = append(points, api.points(receipt))
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(pointsStats(points))
+}
+
+// pointsStats computes the statistics of the points, sorting them in place.
+func pointsStats(points []int) *PointsStatsResponse {
+	var resp PointsStatsResponse
+	if len(points) == 0 {
+		return &resp
+	}
+
+	slices.Sort(points)
+
+	var sum int
+	for _, p := range points {
+		sum += p
+	}
+
+	n := len(points)
+	resp.Count = n
+	resp.Min = points[0]
+	resp.Max = points[n-1]
+	resp.Mean = float64(sum) / float64(n)
+
+	if n%2 == 0 {
+		resp.Median = float64(points[n/2-1]+points[n/2]) / 2
+	} else {
+		resp.Median = float64(points[n/2])
+	}
+
+	return &resp
+}
+
+// ProcessStatsResponse is the response body that is returned from the
+// [ProcessStats] endpoint.
+type ProcessStatsResponse struct {
+	// Succeeded is the number of receipts successfully processed.
+	Succeeded int64 `json:"succeeded"`
+	// Failed is the number of receipts which failed to be processed.
+	Failed int64 `json:"failed"`
+	// Failures are the number of receipts which failed to be processed by
+	// reason, e.g. "malformed_request" or the [Error.Code] of an invalid
+	// receipt such as "invalid_amount".
+	Failures map[string]int64 `json:"failures"`
+}
+
+// ProcessStats is an [http.HandlerFunc] that returns the number of receipts
+// submitted to the [ProcessReceipt] endpoint which succeeded and failed since
+// the API was created or last reset, with failures broken down by reason.
+// Requests rejected before processing, e.g. with `405 Method Not Allowed`,
+// `429 Too Many Requests`, or `503 Service Unavailable`, are not counted.
+func (api *API) ProcessStats(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	resp := ProcessStatsResponse{
+		Succeeded: api.processed.succeeded.Load(),
+		Failures:  make(map[string]int64),
+	}
+
+	api.processed.failures.Range(func(code, n any) bool {
+		count := n.(*atomic.Int64).Load()
+		resp.Failures[code.(string)] = count
+		resp.Failed += count
+		return true
+	})
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(&resp)
+}
+
+// processCounters count the receipts submitted to the [ProcessReceipt]
+// endpoint by outcome, and are safe for concurrent use.
+type processCounters struct {
+	succeeded atomic.Int64
+	// failures maps error codes to *atomic.Int64 counts.
+	failures sync.Map
+}
+
+// countFailure counts a receipt which failed to be processed by the
+// [ProcessReceipt] endpoint for the reason, e.g. "invalid_amount". Requests
+// which were rejected without being processed, e.g. by rate limits, are not
+// counted.
+func (api *API) countFailure(reason string) {
+	n, _ := api.processed.failures.LoadOrStore(reason, new(atomic.Int64))
+	n.(*atomic.Int64).Add(1)
+}
+
+// receiptErrorReason returns the failure reason of an invalid receipt, the
+// code of the error if known, otherwise "invalid_receipt".
+func receiptErrorReason(err error) string {
+	if code := errorCode([]any{err}); code != "" {
+		return code
+	}
+
+	return "invalid_receipt"
+}
+
+// reset clears the counters.
+func (c *processCounters) reset() {
+	c.succeeded.Store(0)
+	c.failures.Range(func(code, _ any) bool {
+		c.failures.Delete(code)
+		return true
+	})
+}
@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPointsStats(tt *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		points  []int
+		deleted []int
+		want    PointsStatsResponse
+	}{
+		{
+			name: "empty",
+			want: PointsStatsResponse{},
+		},
+		{
+			name:   "single",
+			points: []int{28},
+			want:   PointsStatsResponse{Count: 1, Min: 28, Max: 28, Mean: 28, Median: 28},
+		},
+		{
+			name:    "odd count",
+			points:  []int{10, 50, 30, 5, 25},
+			deleted: []int{1000},
+			want:    PointsStatsResponse{Count: 5, Min: 5, Max: 50, Mean: 24, Median: 25},
+		},
+		{
+			name:   "even count",
+			points: []int{10, 0, 31, 20},
+			want:   PointsStatsResponse{Count: 4, Min: 0, Max: 31, Mean: 15.25, Median: 15},
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI()
+
+			var n int
+			save := func(points int, deleted bool) {
+				n++
+				receipt := &Receipt{ID: fmt.Sprintf("receipt-%d", n), Deleted: deleted}
+				receipt.SetPoints(points, "initial calculation", time.Now())
+
+				if err := api.store.Save(context.Background(), receipt); err != nil {
+					t.Fatalf("failed to save receipt, got %v, want no error", err)
+				}
+			}
+
+			for _, points := range tc.points {
+				save(points, false)
+			}
+			for _, points := range tc.deleted {
+				save(points, true)
+			}
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/points/stats", nil)
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to get points stats, got %d status code, want 200", rw.Code)
+			}
+
+			var got PointsStatsResponse
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to parse points stats response, got %v, want no error", err)
+			}
+
+			if got != tc.want {
+				t.Fatalf("points stats do not match, got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProcessStats(t *testing.T) {
+	api := NewAPI()
+
+	const valid = `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`
+
+	bodies := []string{
+		valid,
+		valid,
+		`{`,
+		`{`,
+		`{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.0.0"}`,
+		`{"retailer": "Target", "purchaseDate": "2022-13-01", "purchaseTime": "13:01", "total": "1.00"}`,
+		`{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "25:01", "total": "1.00"}`,
+	}
+
+	// Submit the receipts concurrently to exercise the counters.
+	var wg sync.WaitGroup
+	for _, body := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+		}()
+	}
+	wg.Wait()
+
+	// Errors of other endpoints and requests rejected before processing are
+	// not counted.
+	api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/receipts/unknown/points", nil))
+	api.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/receipts/process", nil))
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/receipts/process/stats", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get process stats, got %d status code, want 200", rw.Code)
+	}
+
+	var got ProcessStatsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse process stats response, got %v, want no error", err)
+	}
+
+	want := ProcessStatsResponse{
+		Succeeded: 2,
+		Failed:    5,
+		Failures: map[string]int64{
+			"malformed_request": 2,
+			"invalid_amount":    1,
+			"invalid_date":      1,
+			"invalid_hour":      1,
+		},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("process stats do not match, got %+v, want %+v", got, want)
+	}
+}
+
+func TestProcessStatsRejected(t *testing.T) {
+	api := NewAPI()
+	api.Use(api.LimitDailyReceipts(1))
+
+	const valid = `{"retailer": "Target", "purchaseDate": "2022-01-01", "purchaseTime": "13:01", "total": "1.00"}`
+
+	for _, status := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/receipts/process", strings.NewReader(valid))
+
+		api.ServeHTTP(rw, req)
+
+		if rw.Code != status {
+			t.Fatalf("process receipt status does not match, got %d, want %d", rw.Code, status)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/receipts/process/stats", nil)
+
+	api.ServeHTTP(rw, req)
+
+	var got ProcessStatsResponse
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse process stats response, got %v, want no error", err)
+	}
+
+	want := ProcessStatsResponse{Succeeded: 1, Failures: map[string]int64{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("process stats do not match, got %+v, want %+v", got, want)
+	}
+}
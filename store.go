@@ -0,0 +1,164 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNotFound is returned by a [Store] when no receipt exists for the given ID.
+var ErrNotFound = errors.New("receipt not found")
+
+// ErrExists is returned by a [Store] when creating a receipt with the ID of an
+// existing receipt.
+var ErrExists = errors.New("receipt already exists")
+
+// Store is the storage for receipts processed by the [API]. Implementations
+// must be safe for concurrent use.
+//
+// Receipts passed to and returned from a Store must not be modified in place,
+// modifications should be made to a copy of the receipt which is then saved.
+type Store interface {
+	// Create stores a new receipt, or returns [ErrExists] if a receipt with
+	// the same ID already exists.
+	Create(ctx context.Context, receipt *Receipt) error
+	// Save stores the receipt, replacing any existing receipt with the same
+	// ID.
+	Save(ctx context.Context, receipt *Receipt) error
+	// Get returns the receipt with the given ID, or [ErrNotFound] if no
+	// receipt exists.
+	Get(ctx context.Context, id string) (*Receipt, error)
+	// GetMany returns the receipts with the given IDs, keyed by ID, from a
+	// single consistent view of the store. IDs with no receipt are omitted.
+	GetMany(ctx context.Context, ids []string) (map[string]*Receipt, error)
+	// List returns all stored receipts in no particular order.
+	List(ctx context.Context) ([]*Receipt, error)
+	// Delete permanently removes the receipt with the given ID, or returns
+	// [ErrNotFound] if no receipt exists.
+	Delete(ctx context.Context, id string) error
+	// HealthCheck returns an error if the store is unable to serve requests,
+	// e.g. due to a lost database connection.
+	HealthCheck(ctx context.Context) error
+}
+
+// MemoryStore is a [Store] that keeps receipts in memory, which are not
+// persisted across restarts.
+//
+// Receipts are copied when stored and when returned, so that callers may
+// modify receipts passed to and returned from the store without corrupting
+// the stored receipts.
+//
+// Receipts are kept in an immutable snapshot which is replaced copy-on-write
+// by writers, allowing reads to proceed without locking. Each write copies the
+// entire map, so writes take O(n) time in the number of stored receipts. This
+// favors read-heavy workloads where receipts are written rarely and read
+// often.
+type MemoryStore struct {
+	mu       sync.Mutex
+	receipts atomic.Pointer[map[string]*Receipt]
+}
+
+// NewMemoryStore creates a new, empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{}
+	store.receipts.Store(&map[string]*Receipt{})
+
+	return store
+}
+
+// Create stores a new receipt, or returns [ErrExists] if a receipt with the
+// same ID already exists.
+func (s *MemoryStore) Create(_ context.Context, receipt *Receipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.snapshot()[receipt.ID]; ok {
+		return ErrExists
+	}
+
+	receipts := maps.Clone(s.snapshot())
+	receipts[receipt.ID] = receipt.Clone()
+	s.receipts.Store(&receipts)
+
+	return nil
+}
+
+// Save stores the receipt, replacing any existing receipt with the same ID.
+func (s *MemoryStore) Save(_ context.Context, receipt *Receipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	receipts := maps.Clone(s.snapshot())
+	receipts[receipt.ID] = receipt.Clone()
+	s.receipts.Store(&receipts)
+
+	return nil
+}
+
+// Get returns the receipt with the given ID, or [ErrNotFound] if no receipt
+// exists.
+func (s *MemoryStore) Get(_ context.Context, id string) (*Receipt, error) {
+	receipt, ok := s.snapshot()[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return receipt.Clone(), nil
+}
+
+// GetMany returns the receipts with the given IDs, keyed by ID, from a single
+// snapshot. IDs with no receipt are omitted.
+func (s *MemoryStore) GetMany(_ context.Context, ids []string) (map[string]*Receipt, error) {
+	snapshot := s.snapshot()
+
+	receipts := make(map[string]*Receipt, len(ids))
+	for _, id := range ids {
+		if receipt, ok := snapshot[id]; ok {
+			receipts[id] = receipt.Clone()
+		}
+	}
+
+	return receipts, nil
+}
+
+// List returns all stored receipts in no particular order.
+func (s *MemoryStore) List(_ context.Context) ([]*Receipt, error) {
+	snapshot := s.snapshot()
+
+	receipts := make([]*Receipt, 0, len(snapshot))
+	for _, receipt := range snapshot {
+		receipts = append(receipts, receipt.Clone())
+	}
+
+	return receipts, nil
+}
+
+// Delete permanently removes the receipt with the given ID, or returns
+// [ErrNotFound] if no receipt exists.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.snapshot()[id]; !ok {
+		return ErrNotFound
+	}
+
+	receipts := maps.Clone(s.snapshot())
+	delete(receipts, id)
+	s.receipts.Store(&receipts)
+
+	return nil
+}
+
+// HealthCheck always succeeds since the memory store is always available.
+func (s *MemoryStore) HealthCheck(_ context.Context) error {
+	return nil
+}
+
+// snapshot returns the current immutable snapshot of stored receipts. The
+// returned map must not be modified.
+func (s *MemoryStore) snapshot() map[string]*Receipt {
+	return *s.receipts.Load()
+}
@@ -0,0 +1,107 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStoreCreate(t *testing.T) {
+	ctx := context.Background()
+
+	store := NewMemoryStore()
+	if err := store.Create(ctx, &Receipt{ID: "id", Points: 28}); err != nil {
+		t.Fatalf("failed to create receipt, got %v, want no error", err)
+	}
+
+	if err := store.Create(ctx, &Receipt{ID: "id", Points: 31}); !errors.Is(err, ErrExists) {
+		t.Fatalf("create error does not match, got %v, want %v", err, ErrExists)
+	}
+
+	receipt, err := store.Get(ctx, "id")
+	if err != nil {
+		t.Fatalf("failed to get receipt, got %v, want no error", err)
+	}
+	if receipt.Points != 28 {
+		t.Fatalf("receipt points do not match, got %d, want 28", receipt.Points)
+	}
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+	ctx := context.Background()
+
+	store := NewMemoryStore()
+
+	saved := &Receipt{ID: "id", Items: []ReceiptItem{{Description: "Pepsi", Price: 125}}}
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatalf("failed to save receipt, got %v, want no error", err)
+	}
+	saved.Items[0].Description = "Coke"
+
+	got, err := store.Get(ctx, "id")
+	if err != nil {
+		t.Fatalf("failed to get receipt, got %v, want no error", err)
+	}
+	got.Items[0].Price = 1
+
+	for _, get := range []func() (*Receipt, error){
+		func() (*Receipt, error) { return store.Get(ctx, "id") },
+		func() (*Receipt, error) {
+			receipts, err := store.List(ctx)
+			return receipts[0], err
+		},
+	} {
+		stored, err := get()
+		if err != nil {
+			t.Fatalf("failed to get receipt, got %v, want no error", err)
+		}
+
+		if want := (ReceiptItem{Description: "Pepsi", Price: 125}); stored.Items[0] != want {
+			t.Fatalf("stored item does not match, got %+v, want %+v", stored.Items[0], want)
+		}
+	}
+}
+
+// BenchmarkMemoryStoreGet measures the read throughput of the copy-on-write
+// snapshot used by [MemoryStore].
+func BenchmarkMemoryStoreGet(b *testing.B) {
+	ctx := context.Background()
+
+	store := NewMemoryStore()
+	store.Save(ctx, &Receipt{ID: "id", Points: 28})
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := store.Get(ctx, "id"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkRWMutexGet measures the read throughput of an RWMutex protected map,
+// as previously used by the API, for comparison with
+// [BenchmarkMemoryStoreGet]. The receipt is cloned as by [MemoryStore.Get], so
+// that only the locking differs.
+func BenchmarkRWMutexGet(b *testing.B) {
+	var mu sync.RWMutex
+	receipts := map[string]*Receipt{
+		"id": {ID: "id", Points: 28},
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.RLock()
+			receipt, ok := receipts["id"]
+			if ok {
+				receipt = receipt.Clone()
+			}
+			mu.RUnlock()
+
+			if !ok || receipt == nil {
+				b.Fatal("missing receipt")
+			}
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithServerTiming configures the API to emit a `Server-Timing` header from
+// the [ProcessReceipt] endpoint breaking down the time spent decoding,
+// validating, scoring, and storing the receipt, for diagnosing latency.
+func WithServerTiming() Option {
+	return func(api *API) {
+		api.serverTiming = true
+	}
+}
+
+// serverTiming records the durations of the phases of handling a request in
+// the `Server-Timing` header of the response. A nil serverTiming records
+// nothing.
+type serverTiming struct {
+	header  http.Header
+	last    time.Time
+	metrics []string
+}
+
+// newServerTiming starts recording phase durations in the header if server
+// timing is enabled, otherwise returning nil.
+func (api *API) newServerTiming(header http.Header) *serverTiming {
+	if !api.serverTiming {
+		return nil
+	}
+
+	return &serverTiming{header: header, last: time.Now()}
+}
+
+// mark records the named phase as having taken the time since the previous
+// phase, or since recording started, and updates the header so that the
+// completed phases are reported even if the request later fails.
+func (st *serverTiming) mark(name string) {
+	if st == nil {
+		return
+	}
+
+	now := time.Now()
+	dur := float64(now.Sub(st.last).Microseconds()) / 1000
+	st.last = now
+
+	st.metrics = append(st.metrics, fmt.Sprintf("%s;dur=%.3f", name, dur))
+	st.header.Set("Server-Timing", strings.Join(st.metrics, ", "))
+}
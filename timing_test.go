@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestServerTiming(tt *testing.T) {
+	body, err := os.ReadFile("testdata/simple-receipt.json")
+	if err != nil {
+		tt.Fatalf("failed to read receipt, got %v, want no error", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{
+			name: "enabled",
+			opts: []Option{WithServerTiming()},
+			want: `^decode;dur=[0-9.]+, validate;dur=[0-9.]+, score;dur=[0-9.]+, store;dur=[0-9.]+$`,
+		},
+		{
+			name: "disabled",
+			want: `^$`,
+		},
+	} {
+		tt.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			api := NewAPI(tc.opts...)
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/receipts/process", bytes.NewReader(body))
+
+			api.ServeHTTP(rw, req)
+
+			if rw.Code != http.StatusOK {
+				t.Fatalf("failed to process receipt, got %d status code, want 200", rw.Code)
+			}
+
+			if got := rw.Header().Get("Server-Timing"); !regexp.MustCompile(tc.want).MatchString(got) {
+				t.Fatalf("server timing header does not match, got %q, want match of %q", got, tc.want)
+			}
+		})
+	}
+}
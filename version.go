@@ -0,0 +1,75 @@
+package fetch
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// Build information which may be set at build time using ldflags, e.g.
+//
+//	go build -ldflags "-X github.com/admtnnr/fetch.commit=$(git rev-parse HEAD)" ...
+//
+// When not set, the values are populated from [debug.ReadBuildInfo] where
+// available and otherwise reported as "unknown".
+var (
+	version   string
+	commit    string
+	buildTime string
+)
+
+// VersionResponse is the response body that is returned from the [Version]
+// endpoint.
+type VersionResponse struct {
+	// Version is the module version of the build.
+	Version string `json:"version"`
+	// Commit is the VCS revision of the build.
+	Commit string `json:"commit"`
+	// BuildTime is the time of the build, or of the VCS revision if the build
+	// time was not provided.
+	BuildTime string `json:"buildTime"`
+}
+
+// Version is an [http.HandlerFunc] that returns the build information of the
+// running API server.
+func (api *API) Version(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		api.WriteError(rw, req, http.StatusMethodNotAllowed, "invalid request method, must be 'GET'")
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	api.encoder(rw, req).Encode(buildVersion())
+}
+
+// buildVersion returns the build information, preferring values set using
+// ldflags over values from the embedded build info.
+func buildVersion() *VersionResponse {
+	resp := VersionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if resp.Version == "" && info.Main.Version != "" {
+			resp.Version = info.Main.Version
+		}
+
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && resp.Commit == "":
+				resp.Commit = setting.Value
+			case setting.Key == "vcs.time" && resp.BuildTime == "":
+				resp.BuildTime = setting.Value
+			}
+		}
+	}
+
+	for _, v := range []*string{&resp.Version, &resp.Commit, &resp.BuildTime} {
+		if *v == "" {
+			*v = "unknown"
+		}
+	}
+
+	return &resp
+}
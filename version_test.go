@@ -0,0 +1,30 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	api := NewAPI()
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/version", nil)
+
+	api.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to get version, got %d status code, want 200", rw.Code)
+	}
+
+	var got map[string]string
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to parse version response, got %v, want no error", err)
+	}
+
+	if got["version"] == "" {
+		t.Fatalf("version response is missing version, got %v, want non-empty version", got)
+	}
+}